@@ -5,13 +5,55 @@ import (
 	"fmt"
 	"os"
 	"pixgrid/server"
+	"time"
 )
 
 func main() {
 	port := flag.Int("port", 8080, "Port to run the server on")
+	timeout := flag.Duration("timeout", 30*time.Second, "Maximum time a single conversion request may take (0 = no limit)")
+	keepDecoded := flag.Bool("keep-decoded", false, "Keep decoded images in memory for faster conversions")
+	adminToken := flag.String("admin-token", os.Getenv("PIXGRID_ADMIN_TOKEN"), "Bearer token for the /api/sessions admin endpoint (disabled when empty)")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file, enables HTTPS together with -tls-key")
+	tlsKey := flag.String("tls-key", "", "TLS private key file")
+	multipartMemory := flag.Int64("multipart-memory", 32<<20, "Bytes of an upload buffered in memory before spilling to a temp file")
+	tempDir := flag.String("temp-dir", "", "Directory for uploads over -multipart-memory (default: system temp dir)")
+	maxJSONBody := flag.Int64("max-json-body", 1<<20, "Maximum request body size in bytes for the JSON endpoints")
+	maxPixels := flag.Int64("max-pixels", 64<<20, "Maximum width*height of an uploaded image, checked before decoding (0 = no limit)")
+	maxConcurrency := flag.Int("max-concurrency", 0, "Maximum conversions running at once; more queue briefly, then get 503 (0 = no limit)")
+	decodeCache := flag.Int("decode-cache", 0, "Decoded uploads shared between sessions with identical bytes (0 = disabled)")
+	decodeCachePixels := flag.Int64("decode-cache-pixels", 32<<20, "Total pixels the -decode-cache may hold")
+	defaultSize := flag.Int("default-size", 64, "Size used when a request doesn't set one")
+	defaultScale := flag.Int("default-scale", 8, "Scale used when a request doesn't set one")
+	defaultColors := flag.Int("default-colors", 0, "Colors used when a request doesn't set them (0 = no quantization, -1 = auto)")
 	flag.Parse()
 
-	srv := server.New()
+	if *defaultSize <= 0 || *defaultScale <= 0 || *defaultColors < -1 {
+		fmt.Println("Error: -default-size and -default-scale must be positive and -default-colors -1 or more")
+		os.Exit(1)
+	}
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		fmt.Println("Error: -tls-cert and -tls-key must be used together")
+		os.Exit(1)
+	}
+
+	opts := []server.Option{
+		server.WithRequestTimeout(*timeout),
+		server.WithDecodedImages(*keepDecoded),
+		server.WithAdminToken(*adminToken),
+		server.WithMultipartMemory(*multipartMemory),
+		server.WithTempDir(*tempDir),
+		server.WithMaxJSONBody(*maxJSONBody),
+		server.WithMaxPixels(*maxPixels),
+		server.WithDefaults(*defaultSize, *defaultScale, *defaultColors),
+		server.WithMaxConcurrency(*maxConcurrency),
+		server.WithDecodeCache(*decodeCache, *decodeCachePixels),
+	}
+	if *tlsCert != "" {
+		opts = append(opts, server.WithTLS(*tlsCert, *tlsKey))
+	}
+
+	srv := server.New(opts...)
 	fmt.Printf("Starting pixgrid server on port %d...\n", *port)
 	if err := srv.Start(*port); err != nil {
 		fmt.Printf("Server error: %v\n", err)
@@ -0,0 +1,144 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+	"slices"
+)
+
+// DominantColor returns the most common color in the image. Colors are
+// bucketed into a coarse 4-bit-per-channel histogram and the average of the
+// fullest bucket is returned. Large images are downsampled first, and fully
+// transparent pixels are ignored
+func DominantColor(img image.Image) color.RGBA {
+	if img.Bounds().Dx() > 128 {
+		img = Downscale(img, 128)
+	}
+
+	bounds := img.Bounds()
+
+	type bucket struct {
+		r, g, b, n uint32
+	}
+	var buckets [4096]bucket
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				continue
+			}
+
+			i := int(c.R>>4)<<8 | int(c.G>>4)<<4 | int(c.B>>4)
+			buckets[i].r += uint32(c.R)
+			buckets[i].g += uint32(c.G)
+			buckets[i].b += uint32(c.B)
+			buckets[i].n++
+		}
+	}
+
+	best := 0
+	for i := range buckets {
+		if buckets[i].n > buckets[best].n {
+			best = i
+		}
+	}
+
+	b := buckets[best]
+	if b.n == 0 {
+		return color.RGBA{}
+	}
+
+	return color.RGBA{R: uint8(b.r / b.n), G: uint8(b.g / b.n), B: uint8(b.b / b.n), A: 255}
+}
+
+// Bounds and coverage of SuggestColorCount
+const (
+	minSuggestedColors = 2
+	maxSuggestedColors = 64
+	suggestCoverage    = 0.95
+)
+
+// SuggestColorCount estimates how many colors img needs: the number of
+// 4-bit-per-channel buckets that together cover 95% of its opaque pixels,
+// fullest first, within 2 to 64. Flat art gets about one color per shade and
+// photos the maximum. Large images are downsampled first
+func SuggestColorCount(img image.Image) int {
+	if img.Bounds().Dx() > 128 {
+		img = Downscale(img, 128)
+	}
+
+	bounds := img.Bounds()
+	var buckets [4096]int
+	total := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				continue
+			}
+			buckets[int(c.R>>4)<<8|int(c.G>>4)<<4|int(c.B>>4)]++
+			total++
+		}
+	}
+
+	counts := buckets[:]
+	slices.SortFunc(counts, func(a, b int) int { return b - a })
+
+	n, covered := 0, 0
+	for _, count := range counts {
+		if float64(covered) >= suggestCoverage*float64(total) || n == maxSuggestedColors {
+			break
+		}
+		covered += count
+		n++
+	}
+	return max(n, minSuggestedColors)
+}
+
+// PerceptualHash computes an average hash: the image is shrunk to 8x8
+// grayscale and each bit records whether that cell is brighter than the mean.
+// Similar images, including resized copies, differ in few bits; compare with
+// HammingDistance
+func PerceptualHash(img image.Image) uint64 {
+	small := sampleBlocks(img, 8, 8, blockAverage)
+
+	var gray [64]uint32
+	var sum uint32
+	for i := range gray {
+		r, g, b, _ := small.At(i%8, i/8).RGBA()
+		gray[i] = (299*(r>>8) + 587*(g>>8) + 114*(b>>8)) / 1000
+		sum += gray[i]
+	}
+
+	mean := sum / 64
+	var hash uint64
+	for i, v := range gray {
+		if v > mean {
+			hash |= 1 << uint(63-i)
+		}
+	}
+	return hash
+}
+
+// HammingDistance counts the bits that differ between two hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// CountColors returns the number of distinct colors in the image, alpha
+// included
+func CountColors(img image.Image) int {
+	bounds := img.Bounds()
+	seen := make(map[uint32]struct{})
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			seen[(r>>8)<<24|(g>>8)<<16|(b>>8)<<8|a>>8] = struct{}{}
+		}
+	}
+
+	return len(seen)
+}
@@ -0,0 +1,148 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// splitImage fills the left leftWidth columns with left and the rest with
+// right
+func splitImage(width, height, leftWidth int, left, right color.Color) *image.NRGBA {
+	img := solidImage(width, height, right)
+	for y := 0; y < height; y++ {
+		for x := 0; x < leftWidth; x++ {
+			img.Set(x, y, left)
+		}
+	}
+	return img
+}
+
+func TestDominantColor(t *testing.T) {
+	red := color.NRGBA{200, 20, 20, 255}
+	blue := color.NRGBA{20, 20, 200, 255}
+	tests := []struct {
+		name string
+		img  image.Image
+		want color.RGBA
+	}{
+		{"solid", solidImage(8, 8, red), color.RGBA{200, 20, 20, 255}},
+		{"majority wins", splitImage(8, 8, 6, red, blue), color.RGBA{200, 20, 20, 255}},
+		{"minority loses", splitImage(8, 8, 2, red, blue), color.RGBA{20, 20, 200, 255}},
+		// 200 and 202 share a bucket, so their average comes back
+		{"bucket average", splitImage(8, 8, 4, red, color.NRGBA{202, 20, 20, 255}), color.RGBA{201, 20, 20, 255}},
+		{"transparent ignored", splitImage(8, 8, 7, color.NRGBA{}, blue), color.RGBA{20, 20, 200, 255}},
+		{"fully transparent", solidImage(8, 8, color.NRGBA{}), color.RGBA{}},
+		{"large image", splitImage(600, 40, 450, red, blue), color.RGBA{200, 20, 20, 255}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DominantColor(tt.img); got != tt.want {
+				t.Errorf("DominantColor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPerceptualHash(t *testing.T) {
+	base := PerceptualHash(gradientImage(64, 64))
+	tests := []struct {
+		name    string
+		img     image.Image
+		maxDist int
+		minDist int
+	}{
+		{"same image", gradientImage(64, 64), 0, 0},
+		{"resized copy", gradientImage(128, 128), 4, 0},
+		{"downscaled copy", Downscale(gradientImage(64, 64), 24), 4, 0},
+		{"inverted", invertImage(gradientImage(64, 64)), 64, 32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := HammingDistance(base, PerceptualHash(tt.img))
+			if d > tt.maxDist || d < tt.minDist {
+				t.Errorf("distance = %d, want between %d and %d", d, tt.minDist, tt.maxDist)
+			}
+		})
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0xff, 0x0f, 4},
+		{0, ^uint64(0), 64},
+	}
+	for _, tt := range tests {
+		if got := HammingDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("HammingDistance(%#x, %#x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// invertImage returns img with every color channel inverted
+func invertImage(img *image.NRGBA) *image.NRGBA {
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255 - img.Pix[i]
+		img.Pix[i+1] = 255 - img.Pix[i+1]
+		img.Pix[i+2] = 255 - img.Pix[i+2]
+	}
+	return img
+}
+
+func TestSuggestColorCount(t *testing.T) {
+	red := color.NRGBA{200, 20, 20, 255}
+	blue := color.NRGBA{20, 20, 200, 255}
+	tests := []struct {
+		name     string
+		img      image.Image
+		min, max int
+	}{
+		{"solid", solidImage(16, 16, red), minSuggestedColors, minSuggestedColors},
+		{"two colors", splitImage(16, 16, 8, red, blue), 2, 2},
+		{"transparent ignored", splitImage(16, 16, 8, color.NRGBA{}, blue), minSuggestedColors, minSuggestedColors},
+		{"photo-like", gradientImage(256, 256), maxSuggestedColors, maxSuggestedColors},
+		{"few shades", bandImage(64, 8, 6), 6, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SuggestColorCount(tt.img); got < tt.min || got > tt.max {
+				t.Errorf("SuggestColorCount() = %d, want %d to %d", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+// bandImage splits a width x height image into n vertical bands of distinct
+// gray levels
+func bandImage(width, height, n int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8(x * n / width * 255 / (n - 1))
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestProcessColorsAuto(t *testing.T) {
+	config := testConfig()
+	config.Colors = ColorsAuto
+	config.Scale = 1
+	result, err := Process(bandImage(64, 16, 5), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Release()
+	if n := CountColors(result.Image); n > 5 {
+		t.Errorf("got %d colors from a 5-shade image", n)
+	}
+}
@@ -0,0 +1,43 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// asciiRamp orders characters from empty to dense
+const asciiRamp = " .:-=+*#%@"
+
+// ToASCII renders img as text, one character per pixel picked from asciiRamp
+// by luminance. Terminal cells are about twice as tall as wide, so each line
+// covers two rows of pixels. With colored set, each character also gets its
+// pixel's color as an ANSI 24-bit escape code
+func ToASCII(img image.Image, colored bool) string {
+	bounds := img.Bounds()
+	var sb strings.Builder
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 2 {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := blockAverage(img, image.Rect(x, y, x+1, min(y+2, bounds.Max.Y)))
+			n := color.NRGBAModel.Convert(c).(color.NRGBA)
+
+			// Transparent pixels stay blank, partly transparent ones fade
+			lum := (0.299*float64(n.R) + 0.587*float64(n.G) + 0.114*float64(n.B)) * float64(n.A) / 255
+			ch := asciiRamp[int(lum/256*float64(len(asciiRamp)))]
+
+			if colored && ch != ' ' {
+				fmt.Fprintf(&sb, "\x1b[38;2;%d;%d;%dm%c", n.R, n.G, n.B, ch)
+			} else {
+				sb.WriteByte(ch)
+			}
+		}
+		if colored {
+			sb.WriteString("\x1b[0m")
+		}
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,106 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"os"
+)
+
+// aspectHeadSize is how much of a file ReadPixelAspect searches for a pHYs
+// chunk, which has to come before the image data
+const aspectHeadSize = 64 << 10
+
+// squareAspectPercent is how far apart, in percent, the two densities may be
+// and still count as square pixels. Metric densities rounded from DPI, like
+// 3780:3779, would otherwise stretch by a pixel in thousands
+const squareAspectPercent = 1
+
+// ReadPixelAspect returns the pixel aspect ratio, width to height, recorded
+// in a PNG pHYs chunk or a JPEG JFIF header, in lowest terms. ok is false
+// when r records none or pixels within squareAspectPercent of square
+func ReadPixelAspect(r io.Reader) (width, height int, ok bool) {
+	head, err := io.ReadAll(io.LimitReader(r, aspectHeadSize))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	// Pixels per unit along each axis; more of them make pixels narrower
+	var xDensity, yDensity int
+	switch {
+	case bytes.HasPrefix(head, pngSignature):
+		for offset := len(pngSignature); offset+8 <= len(head); {
+			length := int(binary.BigEndian.Uint32(head[offset:]))
+			kind := string(head[offset+4 : offset+8])
+			if kind == "IDAT" || length < 0 || offset+12+length > len(head) {
+				break
+			}
+			if kind == "pHYs" && length >= 9 {
+				data := head[offset+8:]
+				xDensity = int(binary.BigEndian.Uint32(data))
+				yDensity = int(binary.BigEndian.Uint32(data[4:]))
+				break
+			}
+			offset += length + 12
+		}
+	case len(head) >= 18 && bytes.HasPrefix(head, []byte("\xff\xd8\xff\xe0")) && string(head[6:11]) == "JFIF\x00":
+		xDensity = int(binary.BigEndian.Uint16(head[14:]))
+		yDensity = int(binary.BigEndian.Uint16(head[16:]))
+	}
+
+	spread := max(xDensity, yDensity) - min(xDensity, yDensity)
+	if xDensity <= 0 || yDensity <= 0 || spread*100 <= max(xDensity, yDensity)*squareAspectPercent {
+		return 0, 0, false
+	}
+	d := gcd(xDensity, yDensity)
+	return yDensity / d, xDensity / d, true
+}
+
+// readFilePixelAspect is ReadPixelAspect for a file
+func readFilePixelAspect(filename string) (width, height int, ok bool) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer file.Close()
+	return ReadPixelAspect(file)
+}
+
+// detectPixelAspect fills in the pixel aspect ratio from the input file when
+// none is configured
+func (c *Config) detectPixelAspect(filename string) {
+	if c.PARWidth != 0 {
+		return
+	}
+	if width, height, ok := readFilePixelAspect(filename); ok {
+		c.PARWidth, c.PARHeight = width, height
+		c.logger().Info("Detected pixel aspect ratio", "par", fmt.Sprintf("%d:%d", width, height))
+	}
+}
+
+// StretchPixelAspect corrects pixels that are width:height rather than
+// square, widening the image when width is larger and heightening it
+// otherwise, with hard pixel edges. 2:1 doubles the width
+func StretchPixelAspect(img image.Image, width, height int) image.Image {
+	if width <= 0 || height <= 0 || width == height {
+		return img
+	}
+
+	bounds := img.Bounds()
+	targetWidth, targetHeight := bounds.Dx(), bounds.Dy()
+	if width > height {
+		targetWidth = proportionalSize(targetWidth, width, height)
+	} else {
+		targetHeight = proportionalSize(targetHeight, height, width)
+	}
+	return resizeNearest(img, targetWidth, targetHeight)
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
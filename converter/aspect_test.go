@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image/color"
+	"testing"
+)
+
+// physPNG returns a small PNG with a pHYs chunk recording xDensity and
+// yDensity pixels per meter
+func physPNG(t *testing.T, xDensity, yDensity uint32) []byte {
+	t.Helper()
+	data := encodeTestPNG(t, gradientImage(4, 4))
+	chunk := make([]byte, 21)
+	binary.BigEndian.PutUint32(chunk, 9)
+	copy(chunk[4:], "pHYs")
+	binary.BigEndian.PutUint32(chunk[8:], xDensity)
+	binary.BigEndian.PutUint32(chunk[12:], yDensity)
+	chunk[16] = 1
+	binary.BigEndian.PutUint32(chunk[17:], crc32.ChecksumIEEE(chunk[4:17]))
+
+	// The chunk goes right after IHDR, before the image data
+	ihdrEnd := len(pngSignature) + 25
+	return append(append(append([]byte{}, data[:ihdrEnd]...), chunk...), data[ihdrEnd:]...)
+}
+
+func TestReadPixelAspect(t *testing.T) {
+	tests := []struct {
+		name                  string
+		xDensity, yDensity    uint32
+		wantWidth, wantHeight int
+		wantOK                bool
+	}{
+		{"square", 2835, 2835, 0, 0, false},
+		{"rounded to square", 3780, 3779, 0, 0, false},
+		{"wide pixels", 1000, 2000, 2, 1, true},
+		{"tall pixels", 3000, 2000, 2, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height, ok := ReadPixelAspect(bytes.NewReader(physPNG(t, tt.xDensity, tt.yDensity)))
+			if ok != tt.wantOK || width != tt.wantWidth || height != tt.wantHeight {
+				t.Errorf("ReadPixelAspect = %d:%d %v, want %d:%d %v",
+					width, height, ok, tt.wantWidth, tt.wantHeight, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStretchPixelAspect(t *testing.T) {
+	img := gradientImage(10, 6)
+	tests := []struct {
+		name          string
+		width, height int
+		want          [2]int
+	}{
+		{"square", 1, 1, [2]int{10, 6}},
+		{"2:1", 2, 1, [2]int{20, 6}},
+		{"1:2", 1, 2, [2]int{10, 12}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := StretchPixelAspect(img, tt.width, tt.height)
+			if got := [2]int{out.Bounds().Dx(), out.Bounds().Dy()}; got != tt.want {
+				t.Fatalf("size = %v, want %v", got, tt.want)
+			}
+			if tt.width == 2 {
+				// Each source pixel becomes two identical ones side by side
+				for x := 0; x < 10; x++ {
+					want := img.NRGBAAt(x, 3)
+					left := color.NRGBAModel.Convert(out.At(2*x, 3))
+					right := color.NRGBAModel.Convert(out.At(2*x+1, 3))
+					if left != want || right != want {
+						t.Fatalf("column %d not doubled", x)
+					}
+				}
+			}
+		})
+	}
+}
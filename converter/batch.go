@@ -0,0 +1,154 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Manifest records the outcome of a batch conversion
+type Manifest struct {
+	Files []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes one converted file. PaletteSize counts the colors
+// of the result, 0 when it has more than fit a palette. Error is set instead
+// of the output fields when the conversion failed, and Skipped when the
+// output already existed and Overwrite was off
+type ManifestEntry struct {
+	Input          string `json:"input"`
+	Output         string `json:"output,omitempty"`
+	OriginalWidth  int    `json:"originalWidth,omitempty"`
+	OriginalHeight int    `json:"originalHeight,omitempty"`
+	FinalWidth     int    `json:"finalWidth,omitempty"`
+	FinalHeight    int    `json:"finalHeight,omitempty"`
+	PaletteSize    int    `json:"paletteSize,omitempty"`
+	Skipped        bool   `json:"skipped,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// Failed returns the number of files that could not be converted
+func (m *Manifest) Failed() int {
+	n := 0
+	for _, entry := range m.Files {
+		if entry.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Skipped returns the number of files left alone because their output
+// already existed
+func (m *Manifest) Skipped() int {
+	n := 0
+	for _, entry := range m.Files {
+		if entry.Skipped {
+			n++
+		}
+	}
+	return n
+}
+
+// ConvertBatch converts every PNG and JPG in inputDir into outputDir, named by
+// the config's OutputTemplate, and writes a manifest.json there describing
+// the results. A failing file is recorded in the manifest and doesn't stop
+// the batch, and existing outputs are skipped unless Overwrite is set
+func ConvertBatch(config Config, inputDir, outputDir string) (*Manifest, error) {
+	entries, err := os.ReadDir(inputDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read input directory: %w", err)
+	}
+
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create output directory: %w", err)
+	}
+
+	if err := config.loadFiles(); err != nil {
+		return nil, err
+	}
+
+	log := config.logger()
+	manifest := &Manifest{Files: []ManifestEntry{}}
+	written := make(map[string]string)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".png" && ext != ".jpg" && ext != ".jpeg") {
+			continue
+		}
+
+		result := ManifestEntry{Input: filepath.Join(inputDir, entry.Name())}
+
+		name, err := config.outputName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		output := filepath.Join(outputDir, name)
+		if previous, taken := written[output]; taken {
+			result.Error = fmt.Sprintf("output %s already written for %s; the template must tell the inputs apart, e.g. with {name} and {ext}", output, previous)
+		} else if err := convertBatchFile(config, result.Input, output, &result); errors.Is(err, fs.ErrExist) {
+			result = ManifestEntry{Input: result.Input, Output: output, Skipped: true}
+			written[output] = result.Input
+		} else if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Output = output
+			written[output] = result.Input
+		}
+
+		if result.Error != "" {
+			log.Warn("Conversion failed", "input", result.Input, "error", result.Error)
+		} else if result.Skipped {
+			log.Info("Skipped existing output", "input", result.Input, "output", result.Output)
+		} else {
+			log.Info("Converted", "input", result.Input, "output", result.Output, "width", result.FinalWidth, "height", result.FinalHeight)
+		}
+
+		manifest.Files = append(manifest.Files, result)
+	}
+
+	if err := writeManifest(filepath.Join(outputDir, "manifest.json"), manifest); err != nil {
+		return manifest, fmt.Errorf("writing manifest: %w", err)
+	}
+
+	return manifest, nil
+}
+
+func convertBatchFile(config Config, input, output string, result *ManifestEntry) error {
+	img, err := loadImage(input)
+	if err != nil {
+		return fmt.Errorf("loading image: %w", err)
+	}
+	config.detectPixelAspect(input)
+
+	processed, err := Process(img, config)
+	if err != nil {
+		return fmt.Errorf("processing image: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+		return fmt.Errorf("could not create output directory: %w", err)
+	}
+	if err := saveOutput(output, processed.Image, config); err != nil {
+		return fmt.Errorf("saving image: %w", err)
+	}
+	result.OriginalWidth = processed.OriginalWidth
+	result.OriginalHeight = processed.OriginalHeight
+	result.FinalWidth = processed.FinalWidth
+	result.FinalHeight = processed.FinalHeight
+	result.PaletteSize = len(processed.Palette)
+
+	return nil
+}
+
+func writeManifest(path string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,42 @@
+package converter
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertBatchPaletteSize(t *testing.T) {
+	inputDir, outputDir := t.TempDir(), t.TempDir()
+	inputs := map[string][]byte{
+		"flat.png":     encodeTestPNG(t, solidImage(32, 32, color.NRGBA{R: 200, A: 255})),
+		"gradient.png": encodeTestPNG(t, gradientImage(32, 32)),
+	}
+	for name, data := range inputs {
+		if err := os.WriteFile(filepath.Join(inputDir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := testConfig()
+	config.Colors = 8
+	manifest, err := ConvertBatch(config, inputDir, outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := make(map[string]int)
+	for _, entry := range manifest.Files {
+		if entry.Error != "" {
+			t.Fatalf("%s failed: %s", entry.Input, entry.Error)
+		}
+		sizes[filepath.Base(entry.Input)] = entry.PaletteSize
+	}
+	if sizes["flat.png"] != 1 {
+		t.Errorf("flat.png palette size = %d, want 1", sizes["flat.png"])
+	}
+	if got := sizes["gradient.png"]; got < 2 || got > 8 {
+		t.Errorf("gradient.png palette size = %d, want 2 to 8", got)
+	}
+}
@@ -0,0 +1,112 @@
+package converter
+
+import (
+	"image"
+	"sync"
+	"sync/atomic"
+)
+
+// maxBufferPools bounds how many distinct image sizes get a pool, so a
+// server seeing endless sizes doesn't grow the map without limit
+const maxBufferPools = 1024
+
+// bufferKey identifies the pool of one image type and size
+type bufferKey struct {
+	width, height int
+	nrgba         bool
+}
+
+// buffers pools the intermediate images of conversions by size, so
+// concurrent conversions reuse pixel buffers instead of allocating a fresh
+// one per stage. sync.Pool hands each buffer to a single caller at a time
+var (
+	buffers     sync.Map // bufferKey -> *sync.Pool
+	bufferPools atomic.Int32
+)
+
+func bufferPool(key bufferKey, create bool) *sync.Pool {
+	if pool, ok := buffers.Load(key); ok {
+		return pool.(*sync.Pool)
+	}
+	if !create || bufferPools.Load() >= maxBufferPools {
+		return nil
+	}
+	pool, loaded := buffers.LoadOrStore(key, &sync.Pool{})
+	if !loaded {
+		bufferPools.Add(1)
+	}
+	return pool.(*sync.Pool)
+}
+
+// newRGBA returns a transparent width x height image, reusing a released
+// buffer of that size when one is free
+func newRGBA(width, height int) *image.RGBA {
+	if pool := bufferPool(bufferKey{width, height, false}, false); pool != nil {
+		if img, ok := pool.Get().(*image.RGBA); ok {
+			clear(img.Pix)
+			return img
+		}
+	}
+	return image.NewRGBA(image.Rect(0, 0, width, height))
+}
+
+// newNRGBA is newRGBA for non-premultiplied images
+func newNRGBA(width, height int) *image.NRGBA {
+	if pool := bufferPool(bufferKey{width, height, true}, false); pool != nil {
+		if img, ok := pool.Get().(*image.NRGBA); ok {
+			clear(img.Pix)
+			return img
+		}
+	}
+	return image.NewNRGBA(image.Rect(0, 0, width, height))
+}
+
+// release hands the buffer of img back for reuse by newRGBA and newNRGBA.
+// Nothing may use img afterwards. Sub-images and other types are ignored
+func release(img image.Image) {
+	var key bufferKey
+	switch img := img.(type) {
+	case *image.RGBA:
+		if img.Rect.Min != (image.Point{}) || len(img.Pix) != cap(img.Pix) || len(img.Pix) != 4*img.Rect.Dx()*img.Rect.Dy() {
+			return
+		}
+		key = bufferKey{img.Rect.Dx(), img.Rect.Dy(), false}
+	case *image.NRGBA:
+		if img.Rect.Min != (image.Point{}) || len(img.Pix) != cap(img.Pix) || len(img.Pix) != 4*img.Rect.Dx()*img.Rect.Dy() {
+			return
+		}
+		key = bufferKey{img.Rect.Dx(), img.Rect.Dy(), true}
+	default:
+		return
+	}
+	if key.width == 0 || key.height == 0 {
+		return
+	}
+
+	if pool := bufferPool(key, true); pool != nil {
+		pool.Put(img)
+	}
+}
+
+// pixels returns the pixel slice behind a poolable image, or nil
+func pixels(img image.Image) []uint8 {
+	switch img := img.(type) {
+	case *image.RGBA:
+		return img.Pix
+	case *image.NRGBA:
+		return img.Pix
+	}
+	return nil
+}
+
+// sharesPixels reports whether a and b are views of the same pixels, as with
+// an image and its sub-image. Only images release can pool are told apart;
+// pixels of other types are never reused, so they needn't be
+func sharesPixels(a, b image.Image) bool {
+	pa, pb := pixels(a), pixels(b)
+	if cap(pa) == 0 || cap(pb) == 0 {
+		return false
+	}
+	// Views of one array end at the same last element
+	return &pa[:cap(pa)][cap(pa)-1] == &pb[:cap(pb)][cap(pb)-1]
+}
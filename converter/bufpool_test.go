@@ -0,0 +1,126 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"sync"
+	"testing"
+)
+
+func TestNewRGBAIsCleared(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		img := newRGBA(8, 8)
+		for j, v := range img.Pix {
+			if v != 0 {
+				t.Fatalf("round %d: byte %d of a new buffer is %d", i, j, v)
+			}
+		}
+		// Dirty the buffer before handing it back
+		for j := range img.Pix {
+			img.Pix[j] = 0xff
+		}
+		release(img)
+	}
+}
+
+func TestSharesPixels(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	b := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	n := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	tests := []struct {
+		name string
+		x, y image.Image
+		want bool
+	}{
+		{"same image", a, a, true},
+		{"sub-image", a, a.SubImage(image.Rect(2, 2, 4, 4)), true},
+		{"different buffers", a, b, false},
+		{"different types", a, n, false},
+		{"unpoolable type", a, image.NewGray(image.Rect(0, 0, 8, 8)), false},
+		{"empty", a, image.NewRGBA(image.Rectangle{}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sharesPixels(tt.x, tt.y); got != tt.want {
+				t.Errorf("sharesPixels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseIgnoresViews(t *testing.T) {
+	// A released sub-image would hand out pixels its parent still uses
+	parent := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	release(parent.SubImage(image.Rect(0, 0, 2, 2)))
+	for i := 0; i < 8; i++ {
+		img := newRGBA(2, 2)
+		if sharesPixels(img, parent) {
+			t.Fatal("newRGBA returned pixels of a released sub-image")
+		}
+	}
+}
+
+func TestPipelineRunConcurrent(t *testing.T) {
+	config := testConfig()
+	config.Colors = 8
+	src := gradientImage(96, 64)
+
+	want, err := Process(src, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPNG := encodeTestPNG(t, want.Image)
+	want.Release()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				result, err := Process(src, config)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				got := encodeTestPNG(t, result.Image)
+				result.Release()
+				if !bytes.Equal(got, wantPNG) {
+					t.Error("concurrent conversion differs from a lone one")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkNewRGBA(b *testing.B) {
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			release(newRGBA(256, 256))
+		}
+	})
+	b.Run("fresh", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = image.NewRGBA(image.Rect(0, 0, 256, 256))
+		}
+	})
+}
+
+func BenchmarkPipelineRunParallel(b *testing.B) {
+	config := testConfig()
+	config.Colors = 16
+	pipeline := DefaultPipeline(config, config.PixelSize)
+	src := gradientImage(512, 512)
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			release(pipeline.Run(src))
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// PadToCanvas centers img on a width x height canvas filled with bg, clipping
+// it when it is larger. A nil bg leaves the padding transparent
+func PadToCanvas(img image.Image, width, height int, bg color.Color) image.Image {
+	canvas := newNRGBA(width, height)
+	if bg != nil {
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	}
+
+	bounds := img.Bounds()
+	offset := image.Pt((width-bounds.Dx())/2, (height-bounds.Dy())/2)
+	draw.Draw(canvas, bounds.Sub(bounds.Min).Add(offset), img, bounds.Min, draw.Over)
+
+	return canvas
+}
+
+// PadToPowerOfTwo pads img with transparency up to the next power-of-two
+// width and height, as many game engines require for textures. The art sits
+// in the top-left corner, or in the middle when center is set; the returned
+// point is its offset within the padded image
+func PadToPowerOfTwo(img image.Image, center bool) (image.Image, image.Point) {
+	bounds := img.Bounds()
+	width := nextPowerOfTwo(bounds.Dx())
+	height := nextPowerOfTwo(bounds.Dy())
+
+	var offset image.Point
+	if center {
+		offset = image.Pt((width-bounds.Dx())/2, (height-bounds.Dy())/2)
+	}
+
+	canvas := newNRGBA(width, height)
+	draw.Draw(canvas, bounds.Sub(bounds.Min).Add(offset), img, bounds.Min, draw.Src)
+
+	return canvas, offset
+}
+
+// nextPowerOfTwo returns the smallest power of two that is at least n
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
@@ -0,0 +1,97 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPadToCanvas(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	blue := color.NRGBA{0, 0, 255, 255}
+	tests := []struct {
+		name          string
+		img           image.Image
+		width, height int
+		bg            color.Color
+		// content is where the image should land on the canvas
+		content image.Rectangle
+		padding color.NRGBA
+	}{
+		{"centered", solidImage(4, 2, red), 8, 6, blue, image.Rect(2, 2, 6, 4), blue},
+		{"transparent padding", solidImage(4, 2, red), 8, 6, nil, image.Rect(2, 2, 6, 4), color.NRGBA{}},
+		{"odd padding", solidImage(3, 3, red), 8, 8, blue, image.Rect(2, 2, 5, 5), blue},
+		{"clipped", solidImage(12, 12, red), 8, 8, blue, image.Rect(0, 0, 8, 8), blue},
+		{"offset bounds", solidImage(10, 10, red).SubImage(image.Rect(3, 3, 7, 5)), 8, 6, blue, image.Rect(2, 2, 6, 4), blue},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PadToCanvas(tt.img, tt.width, tt.height, tt.bg)
+			if size := got.Bounds().Size(); size != image.Pt(tt.width, tt.height) {
+				t.Fatalf("size = %v, want %dx%d", size, tt.width, tt.height)
+			}
+			for y := 0; y < tt.height; y++ {
+				for x := 0; x < tt.width; x++ {
+					want := tt.padding
+					if image.Pt(x, y).In(tt.content) {
+						want = red
+					}
+					if c := color.NRGBAModel.Convert(got.At(x, y)); c != want {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, c, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPadToPowerOfTwo(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	tests := []struct {
+		name   string
+		center bool
+		offset image.Point
+	}{
+		{"top-left", false, image.Pt(0, 0)},
+		{"centered", true, image.Pt(14, 2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, offset := PadToPowerOfTwo(solidImage(100, 60, red), tt.center)
+			if size := got.Bounds().Size(); size != image.Pt(128, 64) {
+				t.Fatalf("size = %v, want 128x64", size)
+			}
+			if offset != tt.offset {
+				t.Errorf("offset = %v, want %v", offset, tt.offset)
+			}
+			content := image.Rect(0, 0, 100, 60).Add(tt.offset)
+			for y := 0; y < 64; y++ {
+				for x := 0; x < 128; x++ {
+					want := color.NRGBA{}
+					if image.Pt(x, y).In(content) {
+						want = red
+					}
+					if c := color.NRGBAModel.Convert(got.At(x, y)); c != want {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, c, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestProcessCanvas(t *testing.T) {
+	config := testConfig()
+	config.CanvasWidth = 50
+	config.CanvasHeight = 40
+	result, err := Process(gradientImage(64, 64), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Release()
+	if result.FinalWidth != 50 || result.FinalHeight != 40 {
+		t.Errorf("final size = %dx%d, want the 50x40 canvas", result.FinalWidth, result.FinalHeight)
+	}
+}
@@ -0,0 +1,27 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// SideBySide places a on the left and b on the right, separated by a gap
+// filled with gapColor. a is resized to b's height, averaging when it
+// shrinks, so both line up
+func SideBySide(a, b image.Image, gap int, gapColor color.Color) image.Image {
+	aBounds, bBounds := a.Bounds(), b.Bounds()
+	height := bBounds.Dy()
+	if aBounds.Dy() != height {
+		width := proportionalSize(aBounds.Dx(), height, aBounds.Dy())
+		a = sampleBlocks(a, width, height, blockAverage)
+		aBounds = a.Bounds()
+	}
+
+	newImg := image.NewRGBA(image.Rect(0, 0, aBounds.Dx()+gap+bBounds.Dx(), height))
+	draw.Draw(newImg, newImg.Bounds(), image.NewUniform(gapColor), image.Point{}, draw.Src)
+	draw.Draw(newImg, image.Rect(0, 0, aBounds.Dx(), height), a, aBounds.Min, draw.Src)
+	draw.Draw(newImg, image.Rect(aBounds.Dx()+gap, 0, newImg.Bounds().Dx(), height), b, bBounds.Min, draw.Src)
+
+	return newImg
+}
@@ -0,0 +1,500 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Conversion modes
+const (
+	// ModePixelArt downscales, quantizes and upscales back with hard edges
+	ModePixelArt = "pixelart"
+	// ModeMosaic averages blocks in place at the original resolution
+	ModeMosaic = "mosaic"
+)
+
+// ColorsAuto as Config.Colors picks a color count for each image with
+// SuggestColorCount. 0 leaves the colors unquantized
+const ColorsAuto = -1
+
+type Config struct {
+	InputFile  string `json:"input"`
+	OutputFile string `json:"output"`
+	PixelSize  int    `json:"size"`
+	Percent    int    `json:"percent"`
+	Scale      int    `json:"scale"`
+	Colors     int    `json:"colors"`
+	Mode       string `json:"mode"`
+
+	// PixelW and PixelH size the block each grid pixel is upscaled to,
+	// defaulting to Scale. Unequal sizes give rectangular pixels, the grid
+	// being squeezed to match so the output keeps the input's aspect ratio
+	PixelW int `json:"pixelW"`
+	PixelH int `json:"pixelH"`
+
+	// OutputTemplate names batch outputs, e.g. "{name}_px{size}.png", with
+	// the placeholders {name}, {ext}, {size}, {scale} and {colors}. Empty
+	// uses DefaultOutputTemplate
+	OutputTemplate string `json:"outputTemplate"`
+
+	// Overwrite lets outputs replace existing files. Without it an existing
+	// output is an error, or skipped in batch mode
+	Overwrite bool `json:"overwrite"`
+
+	// MaxFileSize, when above 0, caps JPEG output at this many bytes by
+	// picking the highest quality that fits
+	MaxFileSize int `json:"maxFileSize"`
+
+	// FetchAttempts is how often an http(s) InputFile is tried before giving
+	// up on network errors or 5xx responses; 0 means 3
+	FetchAttempts int `json:"fetchAttempts"`
+	// URLTimeout bounds each attempt at an http(s) InputFile, in nanoseconds
+	// in JSON; 0 means 30s
+	URLTimeout time.Duration `json:"urlTimeout"`
+
+	// MaxWidth and MaxHeight size the pixel grid to fit within a box instead
+	// of using PixelSize or Percent; either may be 0 for no limit
+	MaxWidth  int `json:"maxWidth"`
+	MaxHeight int `json:"maxHeight"`
+
+	// Sample is how downscaling picks each pixel: SampleCenter (default),
+	// SampleAverage, SampleMode, SampleLanczos or SampleEdge
+	Sample string `json:"sample"`
+
+	// Format is FormatImage to encode by file extension, or a text format.
+	// ANSI colors FormatText output with 24-bit terminal escape codes
+	Format string `json:"format"`
+	ANSI   bool   `json:"ansi"`
+
+	// CompareFile, when set, also gets the original and the result side by
+	// side
+	CompareFile string `json:"compare"`
+
+	// CountColors logs the number of distinct colors in the result
+	CountColors bool `json:"countColors"`
+	// StrictColors guarantees the result has no more colors than Colors or
+	// the palette allow, snapping it back to the palette when later effects
+	// added colors. Transparency doesn't count as a color
+	StrictColors bool `json:"strictColors"`
+
+	// NormalMap also writes a normal map of the result next to the output,
+	// with gradients scaled by NormalStrength
+	NormalMap      bool    `json:"normalMap"`
+	NormalStrength float64 `json:"normalStrength"`
+
+	// Layers also writes one layer_NN.png per palette color next to the
+	// output, each holding only the pixels of that color
+	Layers bool `json:"layers"`
+
+	// PaletteExport also writes the palette quantized to as a GIMP .gpl or
+	// JASC .pal file, for reuse in other tools
+	PaletteExport string `json:"paletteExport"`
+
+	// LUTFile is a tone curve loaded by Convert into LUT, applied before
+	// resizing so quantization sees the graded colors
+	LUTFile string `json:"lut"`
+	LUT     *LUT   `json:"-"`
+
+	Denoise  int     `json:"denoise"`
+	Sharpen  float64 `json:"sharpen"`
+	Blur     float64 `json:"blur"`
+	Vignette float64 `json:"vignette"`
+	// CVD simulates a color vision deficiency on the quantized result, one of
+	// CVDModes; empty or CVDNormal leaves colors alone
+	CVD string `json:"cvd"`
+	// LCD draws each upscaled pixel as RGB subpixel stripes, needing a scale
+	// of at least 3
+	LCD bool `json:"lcd"`
+
+	// Gap leaves this many pixels between upscaled blocks, filled with the
+	// hex GapColor or left transparent, shrinking each tile to Scale-Gap
+	Gap      int    `json:"gap"`
+	GapColor string `json:"gapColor"`
+
+	// Noise adds per-pixel color noise of up to Noise*255 to the small image,
+	// seeded by Seed so the same settings always give the same output
+	Noise float64 `json:"noise"`
+	Seed  int64   `json:"seed"`
+
+	// Trim crops uniform margins (within TrimTolerance of the corner color)
+	// before anything else runs
+	Trim          bool `json:"trim"`
+	TrimTolerance int  `json:"trimTolerance"`
+
+	// ColorKey is an RRGGBB color made transparent after conversion, matching
+	// pixels within ColorKeyTolerance per channel
+	ColorKey          string `json:"colorKey"`
+	ColorKeyTolerance int    `json:"colorKeyTolerance"`
+
+	// SheetCols and SheetRows split the output into a grid of frames written
+	// as separate files. SheetPad allows sizes that don't divide evenly
+	SheetCols int  `json:"sheetCols"`
+	SheetRows int  `json:"sheetRows"`
+	SheetPad  bool `json:"sheetPad"`
+
+	// AlphaThreshold makes alpha binary as the last stage: pixels at or above
+	// it become opaque, the rest transparent. 0 leaves alpha untouched
+	AlphaThreshold int `json:"alphaThreshold"`
+
+	// AlphaLevels reduces alpha to this many evenly spaced steps while
+	// quantizing; 0 passes alpha through
+	AlphaLevels int `json:"alphaLevels"`
+
+	// ValueLevels posterizes the pixel grid's HSV value into this many
+	// bands before quantizing, keeping hue and saturation; 0 disables it
+	ValueLevels int `json:"valueLevels"`
+
+	// PARWidth and PARHeight are the pixel aspect ratio of the input, which
+	// the par stage corrects after upscaling so pixels come out square. 0
+	// reads it from the input file, defaulting to 1:1
+	PARWidth  int `json:"parWidth"`
+	PARHeight int `json:"parHeight"`
+
+	// DuotoneShadow and DuotoneHighlight are hex colors the pixel grid's
+	// luminance is mapped between, black to the first and white to the second
+	DuotoneShadow    string `json:"duotoneShadow"`
+	DuotoneHighlight string `json:"duotoneHighlight"`
+
+	// CanvasWidth and CanvasHeight center the output on a fixed-size canvas,
+	// filled with the RRGGBB CanvasBG or left transparent
+	CanvasWidth  int    `json:"canvasWidth"`
+	CanvasHeight int    `json:"canvasHeight"`
+	CanvasBG     string `json:"canvasBg"`
+
+	// MaskFile is a grayscale image loaded by Convert into Mask, whose
+	// luminance becomes the output's alpha as the final stage
+	MaskFile string      `json:"mask"`
+	Mask     image.Image `json:"-"`
+
+	// PowerOfTwo pads the final output with transparency up to power-of-two
+	// dimensions, keeping the art top-left or centered with PowerOfTwoCenter
+	PowerOfTwo       bool `json:"pot"`
+	PowerOfTwoCenter bool `json:"potCenter"`
+
+	// PaletteName selects a built-in palette and PaletteFile is loaded by
+	// Convert. Either fills Palette; when Palette is set, quantization maps to
+	// it instead of using Colors. PaletteFrom is a reference image whose
+	// median-cut palette of Colors entries becomes Palette, to borrow the
+	// colors of another artwork
+	PaletteName string        `json:"palette"`
+	PaletteFile string        `json:"paletteFile"`
+	PaletteFrom string        `json:"paletteFrom"`
+	Palette     color.Palette `json:"-"`
+	Dither      string        `json:"dither"`
+
+	// DitherLow and DitherHigh, when DitherHigh is above 0, limit dithering
+	// to pixels with a luminance below DitherLow or above DitherHigh, so
+	// midtones stay flat
+	DitherLow  int `json:"ditherLow"`
+	DitherHigh int `json:"ditherHigh"`
+
+	// RecolorFromFile and RecolorToFile are palettes loaded by Convert into
+	// RecolorFrom and RecolorTo. After conversion, pixels matching a From
+	// color take the To color at the same index; RecolorSnap first snaps
+	// unmatched pixels to the nearest From color instead of leaving them
+	RecolorFromFile string        `json:"recolorFrom"`
+	RecolorToFile   string        `json:"recolorTo"`
+	RecolorSnap     bool          `json:"recolorSnap"`
+	RecolorFrom     color.Palette `json:"-"`
+	RecolorTo       color.Palette `json:"-"`
+
+	// Flat maps every pixel to its nearest palette color without dithering,
+	// building the palette by median cut when none is given, for clean logos
+	// and text
+	Flat bool `json:"flat"`
+
+	// BlackWhite replaces quantizing with 1-bit black and white, ordered
+	// dithered through a BayerSize Bayer matrix: 2, 4 or 8, 0 meaning 4
+	BlackWhite bool `json:"bw"`
+	BayerSize  int  `json:"bayerSize"`
+
+	// Stages replaces the downscale, quantize and upscale stages of pixel art
+	// mode, nil stages keeping the defaults, and can reorder all stages
+	Stages Pipeline `json:"stages"`
+
+	// Logger receives progress messages, with per-stage timings at debug
+	// level. Nil uses slog.Default()
+	Logger *slog.Logger `json:"-"`
+}
+
+// DefaultConfig returns the settings used when nothing is specified
+func DefaultConfig() Config {
+	return Config{
+		OutputFile: "output.png",
+		PixelSize:  64,
+		Scale:      8,
+		Colors:     32,
+		Mode:       ModePixelArt,
+		Sample:     SampleCenter,
+		Format:     FormatImage,
+		Dither:     DitherNone,
+
+		NormalStrength: 2,
+	}
+}
+
+func (c Config) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.Default()
+}
+
+// Validate checks that the config describes a runnable conversion
+func (c Config) Validate() error {
+	if c.PixelSize <= 0 {
+		return fmt.Errorf("pixel size must be positive, got %d", c.PixelSize)
+	}
+	if c.Percent < 0 || c.Percent > 100 {
+		return fmt.Errorf("percent must be between 1 and 100, got %d", c.Percent)
+	}
+	if c.MaxWidth < 0 || c.MaxHeight < 0 {
+		return fmt.Errorf("max width and height must not be negative")
+	}
+	if c.Scale <= 0 {
+		return fmt.Errorf("scale must be positive, got %d", c.Scale)
+	}
+	if c.PixelW < 0 || c.PixelH < 0 {
+		return fmt.Errorf("pixel width and height must not be negative")
+	}
+	if w, h := c.blockSize(); w != h && (c.Gap > 0 || c.LCD || c.Mode == ModeMosaic) {
+		return fmt.Errorf("rectangular pixels can't be combined with gap, lcd or mosaic mode")
+	}
+	if c.StrictColors && c.Colors == 0 && len(c.Palette) == 0 && c.PaletteName == "" && c.PaletteFile == "" && !c.BlackWhite {
+		return fmt.Errorf("strict colors needs a color count or palette")
+	}
+	if c.Colors < ColorsAuto {
+		return fmt.Errorf("colors must be %d for auto, 0 for none or a positive count, got %d", ColorsAuto, c.Colors)
+	}
+	if c.Mode != "" {
+		if err := CheckEnum("mode", c.Mode, Modes()); err != nil {
+			return err
+		}
+	}
+	if c.Format != "" {
+		if err := CheckEnum("format", c.Format, Formats()); err != nil {
+			return err
+		}
+	}
+	if c.Sample != "" {
+		if err := CheckEnum("sample mode", c.Sample, SampleModes()); err != nil {
+			return err
+		}
+	}
+	if c.Sharpen < 0 || c.Blur < 0 || c.Denoise < 0 {
+		return fmt.Errorf("denoise, sharpen and blur must not be negative")
+	}
+	if c.Vignette < 0 || c.Vignette > 1 {
+		return fmt.Errorf("vignette must be between 0 and 1, got %g", c.Vignette)
+	}
+	if c.Gap < 0 || (c.Gap > 0 && c.Gap >= c.Scale) {
+		return fmt.Errorf("gap must be between 0 and scale-1, got %d", c.Gap)
+	}
+	if c.Gap > 0 && (c.LCD || c.Mode == ModeMosaic) {
+		return fmt.Errorf("gap can't be combined with lcd or mosaic mode")
+	}
+	if c.GapColor != "" {
+		if _, err := ParseHexColor(c.GapColor); err != nil {
+			return fmt.Errorf("gap color: %w", err)
+		}
+	}
+	if c.LCD && c.Scale < 3 {
+		return fmt.Errorf("lcd needs a scale of at least 3, got %d", c.Scale)
+	}
+	if c.Noise < 0 || c.Noise > 1 {
+		return fmt.Errorf("noise must be between 0 and 1, got %g", c.Noise)
+	}
+	if c.SheetCols < 0 || c.SheetRows < 0 || (c.SheetCols == 0) != (c.SheetRows == 0) {
+		return fmt.Errorf("sheet columns and rows must both be positive to split frames")
+	}
+	if c.AlphaThreshold < 0 || c.AlphaThreshold > 255 {
+		return fmt.Errorf("alpha threshold must be between 0 and 255, got %d", c.AlphaThreshold)
+	}
+	if c.AlphaLevels != 0 && (c.AlphaLevels < 2 || c.AlphaLevels > 256) {
+		return fmt.Errorf("alpha levels must be between 2 and 256, got %d", c.AlphaLevels)
+	}
+	if c.ValueLevels != 0 && (c.ValueLevels < 2 || c.ValueLevels > 256) {
+		return fmt.Errorf("value levels must be between 2 and 256, got %d", c.ValueLevels)
+	}
+	if c.PARWidth < 0 || c.PARHeight < 0 || (c.PARWidth == 0) != (c.PARHeight == 0) {
+		return fmt.Errorf("pixel aspect ratio width and height must both be positive")
+	}
+	if c.CanvasWidth < 0 || c.CanvasHeight < 0 || (c.CanvasWidth == 0) != (c.CanvasHeight == 0) {
+		return fmt.Errorf("canvas width and height must both be positive")
+	}
+	if c.OutputTemplate != "" {
+		if _, err := c.outputName("input.png"); err != nil {
+			return err
+		}
+	}
+	if c.MaxFileSize < 0 {
+		return fmt.Errorf("max file size must not be negative, got %d", c.MaxFileSize)
+	}
+	if c.FetchAttempts < 0 {
+		return fmt.Errorf("fetch attempts must not be negative, got %d", c.FetchAttempts)
+	}
+	if c.URLTimeout < 0 {
+		return fmt.Errorf("URL timeout must not be negative, got %s", c.URLTimeout)
+	}
+	if c.PowerOfTwoCenter && !c.PowerOfTwo {
+		return fmt.Errorf("centering for power-of-two padding needs pot to be enabled")
+	}
+	if (c.DuotoneShadow == "") != (c.DuotoneHighlight == "") {
+		return fmt.Errorf("duotone needs both a shadow and a highlight color")
+	}
+	for _, hex := range []string{c.DuotoneShadow, c.DuotoneHighlight} {
+		if hex == "" {
+			continue
+		}
+		if _, err := ParseHexColor(hex); err != nil {
+			return fmt.Errorf("duotone color: %w", err)
+		}
+	}
+	if c.PaletteExport != "" {
+		if ext := strings.ToLower(filepath.Ext(c.PaletteExport)); ext != ".gpl" && ext != ".pal" {
+			return fmt.Errorf("palette export must be a .gpl or .pal file, got %q", c.PaletteExport)
+		}
+	}
+	if c.CanvasBG != "" {
+		if _, err := ParseHexColor(c.CanvasBG); err != nil {
+			return fmt.Errorf("canvas background: %w", err)
+		}
+	}
+	if c.PaletteName != "" {
+		if err := CheckEnum("palette", c.PaletteName, PaletteNames()); err != nil {
+			return err
+		}
+	}
+	if c.PaletteFrom != "" && c.PaletteFile != "" {
+		return fmt.Errorf("set only one of a palette file and a palette reference image")
+	}
+	if c.PaletteFrom != "" && c.Colors == 0 {
+		return fmt.Errorf("a palette from a reference image needs a color count or auto")
+	}
+	if (c.RecolorFromFile == "") != (c.RecolorToFile == "") {
+		return fmt.Errorf("recolor needs both a from and a to palette")
+	}
+	if len(c.RecolorFrom) != len(c.RecolorTo) {
+		return fmt.Errorf("recolor palettes must have the same number of colors, got %d and %d", len(c.RecolorFrom), len(c.RecolorTo))
+	}
+	if len(c.Stages.Order) > 0 {
+		if _, err := c.processStages(); err != nil {
+			return err
+		}
+	}
+	if c.Flat && c.Dither != "" && c.Dither != DitherNone {
+		return fmt.Errorf("flat can't be combined with dithering")
+	}
+	if c.BayerSize != 0 && c.BayerSize != 2 && c.BayerSize != 4 && c.BayerSize != 8 {
+		return fmt.Errorf("bayer size must be 2, 4 or 8, got %d", c.BayerSize)
+	}
+	if c.BlackWhite && (c.Flat || len(c.Palette) > 0 || c.PaletteName != "" || c.PaletteFile != "" || c.PaletteFrom != "") {
+		return fmt.Errorf("black and white can't be combined with a palette or flat colors")
+	}
+	if c.BlackWhite && c.Dither != "" && c.Dither != DitherNone {
+		return fmt.Errorf("black and white is always ordered dithered, drop the dither mode")
+	}
+	if c.CVD != "" {
+		if err := CheckEnum("color vision mode", c.CVD, CVDModes()); err != nil {
+			return err
+		}
+	}
+	if c.Dither != "" {
+		if err := CheckEnum("dither mode", c.Dither, DitherModes()); err != nil {
+			return err
+		}
+	}
+	if c.DitherLow != 0 || c.DitherHigh != 0 {
+		if c.DitherLow < 0 || c.DitherLow > c.DitherHigh || c.DitherHigh > 255 {
+			return fmt.Errorf("dither range must satisfy 0 <= low <= high <= 255, got %d,%d", c.DitherLow, c.DitherHigh)
+		}
+		if c.Dither == "" || c.Dither == DitherNone {
+			return fmt.Errorf("dither range needs a dither mode")
+		}
+	}
+	if c.ColorKey != "" {
+		if _, err := ParseHexColor(c.ColorKey); err != nil {
+			return fmt.Errorf("color key: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadFiles reads the palette, LUT, recolor and mask files named by the config
+// into their in-memory fields
+func (c *Config) loadFiles() error {
+	if c.PaletteFile != "" {
+		palette, err := LoadPalette(c.PaletteFile)
+		if err != nil {
+			return fmt.Errorf("loading palette: %w", err)
+		}
+		c.Palette = palette
+	}
+
+	if c.PaletteFrom != "" && c.Colors != 0 {
+		ref, err := loadImage(c.PaletteFrom)
+		if err != nil {
+			return fmt.Errorf("loading palette reference: %w", err)
+		}
+		colors := c.Colors
+		if colors == ColorsAuto {
+			colors = SuggestColorCount(ref)
+		}
+		c.Palette = MedianCut(ref, colors)
+	}
+
+	if c.LUTFile != "" {
+		lut, err := LoadLUT(c.LUTFile)
+		if err != nil {
+			return fmt.Errorf("loading LUT: %w", err)
+		}
+		c.LUT = lut
+	}
+
+	if c.RecolorFromFile != "" && c.RecolorToFile != "" {
+		from, err := LoadPalette(c.RecolorFromFile)
+		if err != nil {
+			return fmt.Errorf("loading recolor palette: %w", err)
+		}
+		to, err := LoadPalette(c.RecolorToFile)
+		if err != nil {
+			return fmt.Errorf("loading recolor palette: %w", err)
+		}
+		if len(from) != len(to) {
+			return fmt.Errorf("recolor palettes must have the same number of colors, got %d and %d", len(from), len(to))
+		}
+		c.RecolorFrom, c.RecolorTo = from, to
+	}
+
+	if c.MaskFile != "" {
+		mask, err := loadImage(c.MaskFile)
+		if err != nil {
+			return fmt.Errorf("loading mask: %w", err)
+		}
+		c.Mask = mask
+	}
+
+	return nil
+}
+
+// LoadConfigFile reads a JSON config into config. Fields missing from the
+// file keep their current values, so callers can pre-fill defaults
+func LoadConfigFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("could not parse config: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  func(*Config)
+		wantErr bool
+	}{
+		{"default", func(c *Config) {}, false},
+		{"zero pixel size", func(c *Config) { c.PixelSize = 0 }, true},
+		{"zero scale", func(c *Config) { c.Scale = 0 }, true},
+		{"unknown mode", func(c *Config) { c.Mode = "oil" }, true},
+		{"auto colors", func(c *Config) { c.Colors = ColorsAuto }, false},
+		{"negative colors", func(c *Config) { c.Colors = -2 }, true},
+		{"atkinson", func(c *Config) { c.Colors, c.Dither = 4, DitherAtkinson }, false},
+		{"unknown dither", func(c *Config) { c.Colors, c.Dither = 4, "random" }, true},
+		{"dither range", func(c *Config) { c.Colors, c.Dither, c.DitherLow, c.DitherHigh = 4, DitherOrdered, 64, 192 }, false},
+		{"inverted dither range", func(c *Config) { c.Colors, c.Dither, c.DitherLow, c.DitherHigh = 4, DitherOrdered, 192, 64 }, true},
+		{"dither range without dither", func(c *Config) { c.Colors, c.DitherLow, c.DitherHigh = 4, 64, 192 }, true},
+		{"lanczos sampling", func(c *Config) { c.Sample = SampleLanczos }, false},
+		{"unknown sampling", func(c *Config) { c.Sample = "bicubic" }, true},
+		{"sharpen", func(c *Config) { c.Sharpen = 1.5 }, false},
+		{"negative sharpen", func(c *Config) { c.Sharpen = -1 }, true},
+		{"blur", func(c *Config) { c.Blur = 0.8 }, false},
+		{"negative blur", func(c *Config) { c.Blur = -0.5 }, true},
+		{"denoise", func(c *Config) { c.Denoise = 2 }, false},
+		{"negative denoise", func(c *Config) { c.Denoise = -1 }, true},
+		{"color key", func(c *Config) { c.ColorKey = "ff00ff" }, false},
+		{"bad color key", func(c *Config) { c.ColorKey = "magenta" }, true},
+		{"output template", func(c *Config) { c.OutputTemplate = "{name}_{size}.png" }, false},
+		{"bad output template", func(c *Config) { c.OutputTemplate = "{nme}.png" }, true},
+		{"black and white", func(c *Config) { c.BlackWhite, c.BayerSize = true, 8 }, false},
+		{"bad bayer size", func(c *Config) { c.BlackWhite, c.BayerSize = true, 3 }, true},
+		{"black and white with a palette", func(c *Config) { c.BlackWhite, c.PaletteName = true, "gameboy" }, true},
+		{"black and white with dither", func(c *Config) { c.BlackWhite, c.Dither = true, DitherFloydSteinberg }, true},
+		{"palette export", func(c *Config) { c.PaletteExport = "out.gpl" }, false},
+		{"bad palette export", func(c *Config) { c.PaletteExport = "out.act" }, true},
+		{"negative max file size", func(c *Config) { c.MaxFileSize = -1 }, true},
+		{"negative fetch attempts", func(c *Config) { c.FetchAttempts = -1 }, true},
+		{"url timeout", func(c *Config) { c.URLTimeout = 5 * time.Second }, false},
+		{"negative url timeout", func(c *Config) { c.URLTimeout = -time.Second }, true},
+		{"short gap color", func(c *Config) { c.Scale, c.Gap, c.GapColor = 4, 1, "#abc" }, false},
+		{"bad gap color", func(c *Config) { c.Scale, c.Gap, c.GapColor = 4, 1, "#abcd" }, true},
+		{"canvas", func(c *Config) { c.CanvasWidth, c.CanvasHeight, c.CanvasBG = 64, 48, "#000000" }, false},
+		{"canvas without height", func(c *Config) { c.CanvasWidth = 64 }, true},
+		{"bad canvas background", func(c *Config) { c.CanvasWidth, c.CanvasHeight, c.CanvasBG = 64, 48, "black" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			tt.config(&config)
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,86 +1,484 @@
 package converter
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
+	"image/gif"
 	"image/jpeg"
 	"image/png"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime/debug"
 	"strings"
+	"time"
 )
 
-type Config struct {
-	InputFile  string
-	OutputFile string
-	PixelSize  int
-	Scale      int
-	Colors     int
-}
+// compareGap is the width of the divider in comparison images
+const compareGap = 4
 
 func Convert(config Config) error {
-	img, err := loadImage(config.InputFile)
+	log := config.logger()
+
+	if err := config.loadFiles(); err != nil {
+		return err
+	}
+	if config.PaletteFile != "" || config.PaletteFrom != "" {
+		log.Info("Loaded palette", "colors", len(config.Palette))
+	}
+
+	var frames []Frame
+	var err error
+	if isURL(config.InputFile) {
+		frames, err = loadURLFrames(config)
+	} else {
+		config.detectPixelAspect(config.InputFile)
+		frames, err = loadFrames(config.InputFile)
+	}
 	if err != nil {
 		return fmt.Errorf("loading image: %w", err)
 	}
+	if len(frames) > 1 {
+		return convertAnimation(config, frames)
+	}
 
-	fmt.Printf("Loaded image: %dx%d pixels\n", img.Bounds().Dx(), img.Bounds().Dy())
+	img := frames[0].Image
+	log.Info("Loaded image", "width", img.Bounds().Dx(), "height", img.Bounds().Dy())
 
-	smallImg := Downscale(img, config.PixelSize)
-	fmt.Printf("Downscaled to: %dx%d pixels\n", smallImg.Bounds().Dx(), smallImg.Bounds().Dy())
+	if config.Format != "" && config.Format != FormatImage {
+		return convertToText(img, config)
+	}
 
-	if config.Colors > 0 {
-		smallImg = QuantizeColors(smallImg, config.Colors)
-		fmt.Printf("Reduced to %d colors\n", config.Colors)
+	result, err := Process(img, config)
+	if err != nil {
+		return fmt.Errorf("processing image: %w", err)
 	}
+	finalImg := result.Image
 
-	finalImg := UpscaleNearestNeighbor(smallImg, config.Scale)
-	fmt.Printf("Upscaled to: %dx%d pixels\n", finalImg.Bounds().Dx(), finalImg.Bounds().Dy())
+	log.Info("Converted", "width", finalImg.Bounds().Dx(), "height", finalImg.Bounds().Dy())
+	if config.CountColors {
+		log.Info("Counted colors", "colors", CountColors(finalImg))
+	}
 
-	if err := saveImage(config.OutputFile, finalImg); err != nil {
+	if err := saveOutput(config.OutputFile, finalImg, config); err != nil {
 		return fmt.Errorf("saving image: %w", err)
 	}
 
-	fmt.Printf("Saved to: %s\n", config.OutputFile)
+	log.Info("Saved", "file", config.OutputFile)
+
+	if config.CompareFile != "" {
+		compare := SideBySide(img, finalImg, compareGap, color.White)
+		if err := saveImage(config.CompareFile, compare, config.Overwrite); err != nil {
+			return fmt.Errorf("saving comparison: %w", err)
+		}
+		log.Info("Saved comparison", "file", config.CompareFile)
+	}
+
+	if config.NormalMap {
+		normalFile := suffixPath(config.OutputFile, "_normal")
+		if err := saveImage(normalFile, GenerateNormalMap(finalImg, config.NormalStrength), config.Overwrite); err != nil {
+			return fmt.Errorf("saving normal map: %w", err)
+		}
+		log.Info("Saved normal map", "file", normalFile)
+	}
+
+	if config.Layers {
+		if result.Palette == nil {
+			return fmt.Errorf("saving layers: the result has more than %d colors, quantize it first", maxResultPalette)
+		}
+		if err := saveLayers(finalImg, result.Palette, config); err != nil {
+			return fmt.Errorf("saving layers: %w", err)
+		}
+		log.Info("Saved layers", "count", len(result.Palette))
+	}
+
+	if config.PaletteExport != "" {
+		if result.Palette == nil {
+			return fmt.Errorf("exporting palette: the result has more than %d colors, quantize it first", maxResultPalette)
+		}
+		if err := savePalette(config.PaletteExport, result.Palette, config.Overwrite); err != nil {
+			return fmt.Errorf("exporting palette: %w", err)
+		}
+		log.Info("Exported palette", "file", config.PaletteExport, "colors", len(result.Palette))
+	}
+
+	if config.SheetCols > 0 {
+		if err := saveFrames(finalImg, config); err != nil {
+			return fmt.Errorf("saving frames: %w", err)
+		}
+		log.Info("Saved frames", "count", config.SheetCols*config.SheetRows)
+	}
+
 	return nil
 }
 
-func loadImage(filename string) (image.Image, error) {
+// Process runs the full conversion pipeline on an in-memory image. The file
+// fields of the config are ignored
+func Process(img image.Image, config Config) (*Result, error) {
+	return ProcessContext(context.Background(), img, config)
+}
+
+// ProcessContext is like Process but stops between stages once ctx is done,
+// returning the context's error
+func ProcessContext(ctx context.Context, img image.Image, config Config) (*Result, error) {
+	result, _, err := ProcessContextDone(ctx, img, config)
+	return result, err
+}
+
+// ProcessContextDone is like ProcessContext but also returns a channel that
+// is closed once the conversion has stopped running. That is on return unless
+// ctx ended during a stage, which then finishes in the background; callers
+// limiting concurrent conversions should hold their slot until then
+func ProcessContextDone(ctx context.Context, img image.Image, config Config) (*Result, <-chan struct{}, error) {
+	if err := config.Validate(); err != nil {
+		return nil, closedDone, err
+	}
+
+	stages, err := config.processStages()
+	if err != nil {
+		return nil, closedDone, err
+	}
+
+	result := &Result{
+		OriginalWidth:  img.Bounds().Dx(),
+		OriginalHeight: img.Bounds().Dy(),
+		input:          img,
+	}
+
+	log := config.logger()
+	start := time.Now()
+	for _, stage := range stages {
+		// The upscale stage is always listed, so the grid is caught even
+		// when it is skipped
+		if stage.name == "upscale" {
+			result.Small = img
+		}
+		if stage.apply == nil {
+			continue
+		}
+		stageStart := time.Now()
+		next, running, err := runStage(ctx, stage.apply, img)
+		if err != nil {
+			// The abandoned stage may still be reading img and Small, so
+			// their buffers are left to the garbage collector
+			return nil, running, err
+		}
+		duration := time.Since(stageStart)
+		result.Stages = append(result.Stages, StageTiming{Name: stage.name, Duration: duration})
+		log.Debug("Stage finished", "stage", stage.name, "duration", duration)
+
+		// The stage's input is spent unless it is the caller's image or
+		// still in use
+		if !sharesPixels(img, result.input) && !sharesPixels(img, next) && !sharesPixels(img, result.Small) {
+			release(img)
+		}
+		img = next
+
+		if err := ctx.Err(); err != nil {
+			result.Image = img
+			result.Release()
+			return nil, closedDone, err
+		}
+	}
+
+	result.Palette = resultPalette(result.Small, config)
+	if config.StrictColors {
+		snapped, palette, err := strictColors(img, result.Small, result.Palette, config)
+		if err != nil {
+			result.Image = img
+			result.Release()
+			return nil, closedDone, err
+		}
+		if !sharesPixels(img, snapped) && !sharesPixels(img, result.input) && !sharesPixels(img, result.Small) {
+			release(img)
+		}
+		img, result.Palette = snapped, palette
+	}
+
+	result.Image = img
+	result.FinalWidth = img.Bounds().Dx()
+	result.FinalHeight = img.Bounds().Dy()
+	result.Duration = time.Since(start)
+	return result, closedDone, nil
+}
+
+// closedDone is the already closed channel ProcessContextDone returns when
+// nothing is left running
+var closedDone = func() chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}()
+
+// runStage applies a stage, giving up with the context's error once ctx is
+// done rather than waiting for a long stage to finish. The stage itself runs
+// to completion in the background, closing the returned channel when it does;
+// a panic in it is raised in the caller
+func runStage(ctx context.Context, apply func(image.Image) image.Image, img image.Image) (image.Image, <-chan struct{}, error) {
+	if ctx.Done() == nil {
+		return apply(img), closedDone, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, closedDone, err
+	}
+
+	type outcome struct {
+		img       image.Image
+		recovered any
+		stack     []byte
+	}
+	done := make(chan outcome, 1)
+	running := make(chan struct{})
+	go func() {
+		defer close(running)
+		defer func() {
+			if v := recover(); v != nil {
+				done <- outcome{recovered: v, stack: debug.Stack()}
+			}
+		}()
+		done <- outcome{img: apply(img)}
+	}()
+
+	select {
+	case out := <-done:
+		if out.recovered != nil {
+			panic(fmt.Sprintf("stage panicked: %v\n\n%s", out.recovered, out.stack))
+		}
+		return out.img, closedDone, nil
+	case <-ctx.Done():
+		return nil, running, ctx.Err()
+	}
+}
+
+// quantize reduces colors according to the palette or color count in config,
+// dithering if requested, then alpha to AlphaLevels steps when set
+func quantize(img image.Image, config Config) image.Image {
+	if config.BlackWhite {
+		img = DitherBlackWhite(img, config.BayerSize)
+	} else {
+		if config.Colors == ColorsAuto {
+			config.Colors = SuggestColorCount(img)
+			config.logger().Debug("Picked color count", "colors", config.Colors)
+		}
+		img = quantizeColors(img, config)
+	}
+	if config.AlphaLevels > 0 {
+		img = QuantizeAlpha(img, config.AlphaLevels)
+	}
+	return img
+}
+
+func quantizeColors(img image.Image, config Config) image.Image {
+	palette := config.Palette
+	if len(palette) == 0 && config.PaletteName != "" {
+		palette, _ = NamedPalette(config.PaletteName)
+	}
+
+	dither := config.Dither != "" && config.Dither != DitherNone
+	if len(palette) == 0 && config.Colors > 0 {
+		// An image that already has no more than Colors colors would only
+		// be shifted by the uniform levels, so it is kept as is
+		if _, fits := exactPalette(img, config.Colors); fits {
+			config.logger().Debug("Skipped quantization", "colors", config.Colors)
+			return img
+		}
+		if config.Flat {
+			return QuantizeToPalette(img, MedianCut(img, config.Colors))
+		}
+		if !dither {
+			return QuantizeColors(img, config.Colors)
+		}
+		palette = uniformPalette(config.Colors)
+	}
+	if len(palette) == 0 {
+		return img
+	}
+
+	var gate ditherGate
+	if config.DitherHigh > 0 {
+		gate = outsideLuminance(config.DitherLow, config.DitherHigh)
+	}
+
+	switch config.Dither {
+	case DitherOrdered:
+		return orderedDither(img, palette, gate)
+	case DitherFloydSteinberg:
+		return diffuseError(img, palette, floydSteinbergKernel, gate)
+	case DitherAtkinson:
+		return diffuseError(img, palette, atkinsonKernel, gate)
+	default:
+		return QuantizeToPalette(img, palette)
+	}
+}
+
+// convertAnimation converts every frame and writes them as an animated GIF,
+// the only animated container Go can encode
+func convertAnimation(config Config, frames []Frame) error {
+	log := config.logger()
+	log.Info("Loaded animation", "frames", len(frames), "width", frames[0].Image.Bounds().Dx(), "height", frames[0].Image.Bounds().Dy())
+
+	if ext := strings.ToLower(filepath.Ext(config.OutputFile)); ext != ".gif" {
+		return fmt.Errorf("animated input needs a .gif output, got %q", ext)
+	}
+
+	if err := processFrames(context.Background(), frames, config); err != nil {
+		return fmt.Errorf("processing image: %w", err)
+	}
+
+	file, err := createOutput(config.OutputFile, config.Overwrite)
+	if err != nil {
+		return fmt.Errorf("saving image: %w", err)
+	}
+	defer file.Close()
+
+	if err := encodeAnimatedGIF(file, frames); err != nil {
+		return fmt.Errorf("saving image: %w", err)
+	}
+
+	log.Info("Saved", "file", config.OutputFile)
+	return nil
+}
+
+// loadFrames loads the input as a list of frames: every frame of an animated
+// WebP, or a single frame for anything else
+func loadFrames(filename string) ([]Frame, error) {
+	if strings.ToLower(filepath.Ext(filename)) != ".webp" {
+		img, err := loadImage(filename)
+		if err != nil {
+			return nil, err
+		}
+		return []Frame{{Image: img}}, nil
+	}
+
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("could not open file: %w", err)
 	}
 	defer file.Close()
 
-	img, _, err := image.Decode(file)
+	frames, err := DecodeAnimatedWebP(file)
 	if err != nil {
 		return nil, fmt.Errorf("could not decode image: %w", err)
 	}
+	return frames, nil
+}
+
+func loadImage(filename string) (image.Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file: %w", err)
+	}
+	defer file.Close()
+
+	br := bufio.NewReader(file)
+	head, _ := br.Peek(12)
+	img, _, err := image.Decode(br)
+	if err != nil {
+		return nil, decodeError(head, err)
+	}
 
 	return img, nil
 }
 
-func saveImage(filename string, img image.Image) error {
-	file, err := os.Create(filename)
+// saveImage encodes img by the extension of filename, replacing an existing
+// file only when overwrite is set
+func saveImage(filename string, img image.Image, overwrite bool) error {
+	data, err := encodeImage(filename, img, 0)
 	if err != nil {
-		return fmt.Errorf("could not create file: %w", err)
+		return err
 	}
-	defer file.Close()
+	return writeOutput(filename, data, overwrite)
+}
 
+// saveOutput is like saveImage for the main output of a conversion: a PNG
+// records the settings in a tEXt chunk and a JPEG is fitted within
+// MaxFileSize when set
+func saveOutput(filename string, img image.Image, config Config) error {
+	data, err := encodeImage(filename, img, config.MaxFileSize)
+	if err != nil {
+		return err
+	}
+
+	if bytes.HasPrefix(data, pngSignature) {
+		if data, err = addPNGText(data, MetadataKey, config.metadata()); err != nil {
+			return fmt.Errorf("could not add metadata: %w", err)
+		}
+	}
+
+	return writeOutput(filename, data, config.Overwrite)
+}
+
+// encodeImage encodes img in the format named by the extension of filename.
+// maxBytes, when above 0, limits the size of JPEG output by lowering its
+// quality; other formats can't be fitted and fail
+func encodeImage(filename string, img image.Image, maxBytes int) ([]byte, error) {
 	ext := strings.ToLower(filepath.Ext(filename))
-	
-	switch ext {
+	if maxBytes > 0 {
+		if ext != ".jpg" && ext != ".jpeg" {
+			return nil, fmt.Errorf("a maximum file size needs JPEG output, got %s", ext)
+		}
+		return EncodeJPEGUnderSize(img, maxBytes)
+	}
+	return EncodeAs(img, ext)
+}
+
+// EncodeAs encodes img in the format of the file extension ext: .png, .jpg,
+// .jpeg or .gif, with the settings Convert uses for output files
+func EncodeAs(img image.Image, ext string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch strings.ToLower(ext) {
 	case ".png":
-		err = png.Encode(file, img)
+		err = png.Encode(&buf, img)
 	case ".jpg", ".jpeg":
-		err = jpeg.Encode(file, img, &jpeg.Options{Quality: 95})
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	case ".gif":
+		err = gif.Encode(&buf, toPaletted(img), nil)
 	default:
-		return fmt.Errorf("unsupported output format: %s", ext)
+		return nil, fmt.Errorf("unsupported output format: %s", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// createOutput creates filename for writing. Unless overwrite is set, an
+// existing file is an error wrapping fs.ErrExist; O_EXCL makes the check and
+// the creation a single step so nothing can slip in between
+func createOutput(filename string, overwrite bool) (*os.File, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !overwrite {
+		flags |= os.O_EXCL
 	}
 
+	file, err := os.OpenFile(filename, flags, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("%s: %w, pass -overwrite to replace it", filename, fs.ErrExist)
+	}
 	if err != nil {
-		return fmt.Errorf("could not encode image: %w", err)
+		return nil, fmt.Errorf("could not create file: %w", err)
 	}
+	return file, nil
+}
 
+// writeOutput writes data to filename through createOutput
+func writeOutput(filename string, data []byte, overwrite bool) error {
+	file, err := createOutput(filename, overwrite)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("could not write file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("could not write file: %w", err)
+	}
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,124 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Color vision deficiencies SimulateColorBlindness can show
+const (
+	CVDNormal       = "normal"
+	CVDProtanopia   = "protanopia"
+	CVDDeuteranopia = "deuteranopia"
+	CVDTritanopia   = "tritanopia"
+)
+
+// CVDModes lists the color vision deficiency simulation modes
+func CVDModes() []string {
+	return []string{CVDNormal, CVDProtanopia, CVDDeuteranopia, CVDTritanopia}
+}
+
+type matrix3 [3][3]float64
+
+func (m matrix3) mul(n matrix3) matrix3 {
+	var out matrix3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				out[i][j] += m[i][k] * n[k][j]
+			}
+		}
+	}
+	return out
+}
+
+// Linear RGB to LMS cone responses and back, after Viénot, Brettel and
+// Mollon (1999)
+var (
+	rgbToLMS = matrix3{
+		{17.8824, 43.5161, 4.11935},
+		{3.45565, 27.1554, 3.86714},
+		{0.0299566, 0.184309, 1.46709},
+	}
+	lmsToRGB = matrix3{
+		{0.0809444479, -0.130504409, 0.116721066},
+		{-0.0102485335, 0.0540193266, -0.113614708},
+		{-0.000365296938, -0.00412161469, 0.693511405},
+	}
+)
+
+// cvdMatrices map linear RGB to what a dichromat perceives: the missing
+// cone's response is rebuilt from the remaining two, keeping white and, for
+// tritanopia, red unchanged
+var cvdMatrices = map[string]matrix3{
+	CVDProtanopia: lmsToRGB.mul(matrix3{
+		{0, 2.02344, -2.52581},
+		{0, 1, 0},
+		{0, 0, 1},
+	}).mul(rgbToLMS),
+	CVDDeuteranopia: lmsToRGB.mul(matrix3{
+		{1, 0, 0},
+		{0.494207, 0, 1.24827},
+		{0, 0, 1},
+	}).mul(rgbToLMS),
+	CVDTritanopia: lmsToRGB.mul(matrix3{
+		{1, 0, 0},
+		{0, 1, 0},
+		{-0.0122450, 0.0720345, 0},
+	}).mul(rgbToLMS),
+}
+
+// SimulateColorBlindness shows img as seen with protanopia, deuteranopia or
+// tritanopia, keeping alpha. "normal", an empty mode or an unknown one return
+// img unchanged
+func SimulateColorBlindness(img image.Image, mode string) image.Image {
+	m, ok := cvdMatrices[mode]
+	if !ok {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	var toLinear [256]float64
+	for i := range toLinear {
+		toLinear[i] = srgbToLinear(float64(i) / 255)
+	}
+
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			in := [3]float64{toLinear[c.R], toLinear[c.G], toLinear[c.B]}
+
+			var out [3]uint8
+			for i := 0; i < 3; i++ {
+				v := m[i][0]*in[0] + m[i][1]*in[1] + m[i][2]*in[2]
+				out[i] = clampUint8(linearToSRGB(v)*255, 255)
+			}
+			newImg.SetNRGBA(x, y, color.NRGBA{R: out[0], G: out[1], B: out[2], A: c.A})
+		}
+	}
+
+	return newImg
+}
+
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) float64 {
+	if v <= 0 {
+		return 0
+	}
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
@@ -0,0 +1,31 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSimulateColorBlindness(t *testing.T) {
+	src := gradientImage(16, 16)
+	for _, mode := range []string{CVDNormal, ""} {
+		if got := SimulateColorBlindness(src, mode); got != image.Image(src) {
+			t.Errorf("mode %q changed the image", mode)
+		}
+	}
+
+	// Every simulation keeps grays gray and changes a colorful image
+	gray := color.NRGBA{128, 128, 128, 255}
+	for _, mode := range []string{CVDProtanopia, CVDDeuteranopia, CVDTritanopia} {
+		t.Run(mode, func(t *testing.T) {
+			img := SimulateColorBlindness(solidImage(2, 2, gray), mode)
+			if c := color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA); !nearColor(c, gray, 2) {
+				t.Errorf("gray became %v", c)
+			}
+			if bytes.Equal(encodeTestPNG(t, SimulateColorBlindness(src, mode)), encodeTestPNG(t, src)) {
+				t.Error("the gradient came out unchanged")
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Dithering modes
+const (
+	DitherNone           = "none"
+	DitherOrdered        = "ordered"
+	DitherFloydSteinberg = "floyd-steinberg"
+	DitherAtkinson       = "atkinson"
+)
+
+// bayer4 is the 4x4 Bayer threshold matrix
+var bayer4 = [4][4]float64{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// ditherGate reports whether a pixel of the source gets dithered. A nil gate
+// dithers every pixel
+type ditherGate func(c color.NRGBA) bool
+
+// outsideLuminance dithers only pixels whose luminance lies outside
+// [lo, hi], leaving midtones cleanly posterized
+func outsideLuminance(lo, hi int) ditherGate {
+	// Luminance in thousandths, so grays exactly at a bound aren't pushed
+	// out by float rounding
+	return func(c color.NRGBA) bool {
+		lum := 299*int(c.R) + 587*int(c.G) + 114*int(c.B)
+		return lum < lo*1000 || lum > hi*1000
+	}
+}
+
+// OrderedDither maps the image to palette after offsetting each pixel
+// by a Bayer threshold, giving the regular crosshatch of retro hardware
+func OrderedDither(img image.Image, palette color.Palette) image.Image {
+	return orderedDither(img, palette, nil)
+}
+
+func orderedDither(img image.Image, palette color.Palette, gate ditherGate) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	// Fewer colors are further apart and need a wider threshold spread
+	spread := 256 / math.Cbrt(float64(len(palette)))
+
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			offset := ((bayer4[y%4][x%4]+0.5)/16 - 0.5) * spread
+			if gate != nil && !gate(c) {
+				offset = 0
+			}
+
+			shifted := color.NRGBA{
+				R: clampUint8(float64(c.R)+offset, 255),
+				G: clampUint8(float64(c.G)+offset, 255),
+				B: clampUint8(float64(c.B)+offset, 255),
+			}
+			p := color.NRGBAModel.Convert(palette[nearestIndex(palette, shifted)]).(color.NRGBA)
+			newImg.SetNRGBA(x, y, color.NRGBA{R: p.R, G: p.G, B: p.B, A: c.A})
+		}
+	}
+
+	return newImg
+}
+
+// diffusionWeight spreads a share of the quantization error to the pixel at
+// (dx, dy) relative to the current one
+type diffusionWeight struct {
+	dx, dy int
+	weight float64
+}
+
+var floydSteinbergKernel = []diffusionWeight{
+	{1, 0, 7.0 / 16},
+	{-1, 1, 3.0 / 16},
+	{0, 1, 5.0 / 16},
+	{1, 1, 1.0 / 16},
+}
+
+// FloydSteinbergDither maps the image to palette, diffusing each pixel's
+// quantization error onto its unvisited neighbors
+func FloydSteinbergDither(img image.Image, palette color.Palette) image.Image {
+	return diffuseError(img, palette, floydSteinbergKernel, nil)
+}
+
+// atkinsonKernel passes 1/8 of the error to each of six neighbors and drops
+// the remaining 2/8, which keeps highlights and shadows clean
+var atkinsonKernel = []diffusionWeight{
+	{1, 0, 1.0 / 8},
+	{2, 0, 1.0 / 8},
+	{-1, 1, 1.0 / 8},
+	{0, 1, 1.0 / 8},
+	{1, 1, 1.0 / 8},
+	{0, 2, 1.0 / 8},
+}
+
+// AtkinsonDither maps the image to palette with the partial error diffusion
+// of classic Mac software, giving higher contrast than Floyd-Steinberg
+func AtkinsonDither(img image.Image, palette color.Palette) image.Image {
+	return diffuseError(img, palette, atkinsonKernel, nil)
+}
+
+// diffuseError is the shared error diffusion loop: pixels are visited in
+// scanline order and the error of each is distributed according to kernel.
+// Pixels the gate rejects neither pass on nor receive error
+func diffuseError(img image.Image, palette color.Palette, kernel []diffusionWeight, gate ditherGate) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	// Working copy of the RGB channels accumulating diffused error
+	buf := make([]float64, width*height*3)
+	alpha := make([]uint8, width*height)
+	dither := make([]bool, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			i := y*width + x
+			buf[i*3] = float64(c.R)
+			buf[i*3+1] = float64(c.G)
+			buf[i*3+2] = float64(c.B)
+			alpha[i] = c.A
+			dither[i] = gate == nil || gate(c)
+		}
+	}
+
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			old := [3]float64{buf[i*3], buf[i*3+1], buf[i*3+2]}
+			c := color.NRGBA{
+				R: clampUint8(old[0], 255),
+				G: clampUint8(old[1], 255),
+				B: clampUint8(old[2], 255),
+			}
+			p := color.NRGBAModel.Convert(palette[nearestIndex(palette, c)]).(color.NRGBA)
+			newImg.SetNRGBA(x, y, color.NRGBA{R: p.R, G: p.G, B: p.B, A: alpha[i]})
+			if !dither[i] {
+				continue
+			}
+
+			errs := [3]float64{old[0] - float64(p.R), old[1] - float64(p.G), old[2] - float64(p.B)}
+			for _, k := range kernel {
+				nx, ny := x+k.dx, y+k.dy
+				if nx < 0 || nx >= width || ny >= height || !dither[ny*width+nx] {
+					continue
+				}
+				j := (ny*width + nx) * 3
+				buf[j] += errs[0] * k.weight
+				buf[j+1] += errs[1] * k.weight
+				buf[j+2] += errs[2] * k.weight
+			}
+		}
+	}
+
+	return newImg
+}
+
+// bayerMatrix returns the size x size Bayer threshold matrix, size being a
+// power of two, with entries 0 to size*size-1
+func bayerMatrix(size int) [][]int {
+	if size <= 1 {
+		return [][]int{{0}}
+	}
+	half := bayerMatrix(size / 2)
+	matrix := make([][]int, size)
+	for y := range matrix {
+		matrix[y] = make([]int, size)
+		for x := range matrix[y] {
+			// Each quadrant repeats the half-size matrix, offset in the
+			// order 0, 2, 3, 1
+			v := 4 * half[y%(size/2)][x%(size/2)]
+			switch {
+			case y < size/2 && x >= size/2:
+				v += 2
+			case y >= size/2 && x < size/2:
+				v += 3
+			case y >= size/2 && x >= size/2:
+				v++
+			}
+			matrix[y][x] = v
+		}
+	}
+	return matrix
+}
+
+// DitherBlackWhite converts the image to 1-bit black and white, comparing
+// each pixel's luminance against a matrixSize x matrixSize Bayer threshold
+// for the classic early Mac look. matrixSize is 2, 4 or 8; other sizes use
+// 4. Alpha is kept
+func DitherBlackWhite(img image.Image, matrixSize int) image.Image {
+	if matrixSize != 2 && matrixSize != 8 {
+		matrixSize = 4
+	}
+	matrix := bayerMatrix(matrixSize)
+	levels := float64(matrixSize * matrixSize)
+
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			lum := 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+			threshold := (float64(matrix[y%matrixSize][x%matrixSize]) + 0.5) / levels * 255
+
+			var v uint8
+			if lum > threshold {
+				v = 255
+			}
+			newImg.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: c.A})
+		}
+	}
+
+	return newImg
+}
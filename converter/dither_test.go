@@ -0,0 +1,199 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+var blackWhite = color.Palette{color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}}
+
+// countWhite counts the pure white pixels of img, failing on any pixel that
+// is neither black nor white
+func countWhite(t *testing.T, img image.Image) int {
+	t.Helper()
+	white := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			switch {
+			case c.R == 255 && c.G == 255 && c.B == 255:
+				white++
+			case c.R == 0 && c.G == 0 && c.B == 0:
+			default:
+				t.Fatalf("pixel (%d,%d) = %v is not black or white", x, y, c)
+			}
+		}
+	}
+	return white
+}
+
+func TestDither(t *testing.T) {
+	dithers := []struct {
+		name   string
+		dither func(image.Image, color.Palette) image.Image
+	}{
+		{"ordered", OrderedDither},
+		{"floyd-steinberg", FloydSteinbergDither},
+		{"atkinson", AtkinsonDither},
+	}
+	tests := []struct {
+		name               string
+		gray               uint8
+		minWhite, maxWhite int
+	}{
+		{"black", 0, 0, 0},
+		{"white", 255, 256, 256},
+		{"mid gray", 128, 96, 160},
+		{"light gray", 192, 160, 224},
+	}
+
+	for _, d := range dithers {
+		for _, tt := range tests {
+			t.Run(d.name+"/"+tt.name, func(t *testing.T) {
+				img := d.dither(solidImage(16, 16, color.NRGBA{tt.gray, tt.gray, tt.gray, 255}), blackWhite)
+				if white := countWhite(t, img); white < tt.minWhite || white > tt.maxWhite {
+					t.Errorf("%d of 256 pixels white, want %d to %d", white, tt.minWhite, tt.maxWhite)
+				}
+			})
+		}
+	}
+}
+
+func TestAtkinsonKeepsHighlightsClean(t *testing.T) {
+	// Atkinson drops a quarter of the error, so a near-white area gets fewer
+	// dark specks than with Floyd-Steinberg
+	src := solidImage(32, 32, color.NRGBA{240, 240, 240, 255})
+	atkinson := 1024 - countWhite(t, AtkinsonDither(src, blackWhite))
+	floyd := 1024 - countWhite(t, FloydSteinbergDither(src, blackWhite))
+	if atkinson >= floyd {
+		t.Errorf("Atkinson left %d black pixels, Floyd-Steinberg %d; want fewer", atkinson, floyd)
+	}
+}
+
+func TestOutsideLuminance(t *testing.T) {
+	gate := outsideLuminance(64, 192)
+	tests := []struct {
+		gray uint8
+		want bool
+	}{
+		{0, true},
+		{63, true},
+		{64, false},
+		{128, false},
+		{192, false},
+		{193, true},
+		{255, true},
+	}
+	for _, tt := range tests {
+		if got := gate(color.NRGBA{tt.gray, tt.gray, tt.gray, 255}); got != tt.want {
+			t.Errorf("gray %d: dithered = %v, want %v", tt.gray, got, tt.want)
+		}
+	}
+}
+
+func TestDitherRange(t *testing.T) {
+	kernels := []struct {
+		name   string
+		dither func(image.Image, color.Palette, ditherGate) image.Image
+	}{
+		{"ordered", orderedDither},
+		{"floyd-steinberg", func(img image.Image, palette color.Palette, gate ditherGate) image.Image {
+			return diffuseError(img, palette, floydSteinbergKernel, gate)
+		}},
+		{"atkinson", func(img image.Image, palette color.Palette, gate ditherGate) image.Image {
+			return diffuseError(img, palette, atkinsonKernel, gate)
+		}},
+	}
+	tests := []struct {
+		name string
+		gray uint8
+		// dithered is whether the flat area should come out as a mix of
+		// black and white rather than one solid color
+		dithered bool
+	}{
+		{"shadow", 40, true},
+		{"midtone", 100, false},
+		{"highlight", 215, true},
+	}
+
+	gate := outsideLuminance(64, 192)
+	for _, k := range kernels {
+		for _, tt := range tests {
+			t.Run(k.name+"/"+tt.name, func(t *testing.T) {
+				img := k.dither(solidImage(16, 16, color.NRGBA{tt.gray, tt.gray, tt.gray, 255}), blackWhite, gate)
+				white := countWhite(t, img)
+				mixed := white > 0 && white < 256
+				if mixed != tt.dithered {
+					t.Errorf("%d of 256 pixels white, want dithered %v", white, tt.dithered)
+				}
+			})
+		}
+	}
+}
+
+func TestBayerMatrix(t *testing.T) {
+	for _, size := range []int{2, 4, 8} {
+		matrix := bayerMatrix(size)
+		seen := make(map[int]bool)
+		for _, row := range matrix {
+			for _, v := range row {
+				if v < 0 || v >= size*size || seen[v] {
+					t.Fatalf("size %d: bad or repeated entry %d", size, v)
+				}
+				seen[v] = true
+			}
+		}
+	}
+	// The 4x4 matrix matches the fixed one OrderedDither uses
+	for y, row := range bayerMatrix(4) {
+		for x, v := range row {
+			if float64(v) != bayer4[y][x] {
+				t.Fatalf("bayerMatrix(4)[%d][%d] = %d, want %g", y, x, v, bayer4[y][x])
+			}
+		}
+	}
+}
+
+func TestDitherBlackWhite(t *testing.T) {
+	tests := []struct {
+		name       string
+		gray       uint8
+		matrixSize int
+		// want is the share of white pixels, which follows the gray level
+		want float64
+	}{
+		{"black", 0, 4, 0},
+		{"white", 255, 4, 1},
+		{"quarter", 64, 4, 0.25},
+		{"half 2x2", 128, 2, 0.5},
+		{"half 8x8", 128, 8, 0.5},
+		{"three quarters", 192, 8, 0.75},
+		{"bad size uses 4", 128, 3, 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := DitherBlackWhite(solidImage(16, 16, color.NRGBA{tt.gray, tt.gray, tt.gray, 255}), tt.matrixSize)
+			if got := float64(countWhite(t, img)) / 256; got != tt.want {
+				t.Errorf("%.3f of the pixels are white, want %.3f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessBlackWhite(t *testing.T) {
+	config := testConfig()
+	config.BlackWhite = true
+	config.BayerSize = 8
+	result, err := Process(gradientImage(64, 64), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Release()
+	if n := CountColors(result.Image); n != 2 {
+		t.Errorf("got %d colors, want exactly black and white", n)
+	}
+	countWhite(t, result.Image)
+}
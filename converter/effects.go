@@ -0,0 +1,290 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand/v2"
+)
+
+// Vignette darkens the image towards the corners. Brightness falls off
+// quadratically with the distance from the center, reaching 1-strength at the
+// corners while the center stays unchanged
+func Vignette(img image.Image, strength float64) image.Image {
+	if strength <= 0 {
+		return img
+	}
+	if strength > 1 {
+		strength = 1
+	}
+
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	newImg := newRGBA(width, height)
+
+	cx := float64(width) / 2
+	cy := float64(height) / 2
+	maxDist := math.Hypot(cx, cy)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+
+			d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) / maxDist
+			factor := 1 - strength*d*d
+
+			newImg.Set(x, y, color.RGBA{
+				R: clampUint8(float64(r>>8)*factor, 255),
+				G: clampUint8(float64(g>>8)*factor, 255),
+				B: clampUint8(float64(b>>8)*factor, 255),
+				A: uint8(a >> 8),
+			})
+		}
+	}
+
+	return newImg
+}
+
+// ApplyColorKey makes every pixel within tolerance of key (per RGB channel)
+// fully transparent, as used by sprite workflows with a magenta background
+func ApplyColorKey(img image.Image, key color.Color, tolerance int) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	k := color.NRGBAModel.Convert(key).(color.NRGBA)
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			if channelDiff(c.R, k.R) <= tolerance && channelDiff(c.G, k.G) <= tolerance && channelDiff(c.B, k.B) <= tolerance {
+				c = color.NRGBA{}
+			}
+			newImg.SetNRGBA(x, y, c)
+		}
+	}
+
+	return newImg
+}
+
+func channelDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// ThresholdAlpha makes every pixel either fully opaque or fully transparent:
+// alpha at or above threshold becomes 255, anything below becomes 0
+func ThresholdAlpha(img image.Image, threshold uint8) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			if c.A >= threshold {
+				c.A = 255
+			} else {
+				c = color.NRGBA{}
+			}
+			newImg.SetNRGBA(x, y, c)
+		}
+	}
+
+	return newImg
+}
+
+// ApplyMask sets the alpha of every pixel from the luminance of mask, which is
+// stretched to img's size: white keeps the pixel, black makes it transparent.
+// Existing transparency is kept by multiplying with it
+func ApplyMask(img, mask image.Image) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+	maskBounds := mask.Bounds()
+
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		my := maskBounds.Min.Y + y*maskBounds.Dy()/height
+		for x := 0; x < width; x++ {
+			mx := maskBounds.Min.X + x*maskBounds.Dx()/width
+			lum := color.GrayModel.Convert(mask.At(mx, my)).(color.Gray).Y
+
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			c.A = uint8((int(c.A)*int(lum) + 127) / 255)
+			newImg.SetNRGBA(x, y, c)
+		}
+	}
+
+	return newImg
+}
+
+// QuantizeAlpha reduces alpha to levels evenly spaced steps from 0 to 255,
+// leaving the colors alone. 2 levels make alpha binary at the midpoint
+func QuantizeAlpha(img image.Image, levels int) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	steps := levels - 1
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			level := (int(c.A)*steps + 127) / 255
+			c.A = uint8((level*255 + steps/2) / steps)
+			newImg.SetNRGBA(x, y, c)
+		}
+	}
+
+	return newImg
+}
+
+// PosterizeValue quantizes the HSV value (brightness) of every pixel to
+// levels evenly spaced bands while hue and saturation stay continuous, for a
+// cel-shaded look. With hue and saturation fixed, RGB scales with value, so
+// each pixel is scaled by its new value over its old one. Alpha is preserved
+func PosterizeValue(img image.Image, levels int) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	steps := levels - 1
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			value := max(c.R, c.G, c.B)
+			if value > 0 {
+				level := (int(value)*steps + 127) / 255
+				ratio := float64((level*255+steps/2)/steps) / float64(value)
+				c.R = clampUint8(float64(c.R)*ratio, 255)
+				c.G = clampUint8(float64(c.G)*ratio, 255)
+				c.B = clampUint8(float64(c.B)*ratio, 255)
+			}
+			newImg.SetNRGBA(x, y, c)
+		}
+	}
+
+	return newImg
+}
+
+// Duotone maps the luminance of every pixel onto the gradient from shadow
+// (black) to highlight (white), for a two-color print look. Alpha is
+// preserved
+func Duotone(img image.Image, shadow, highlight color.Color) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	lo := color.NRGBAModel.Convert(shadow).(color.NRGBA)
+	hi := color.NRGBAModel.Convert(highlight).(color.NRGBA)
+	mix := func(a, b uint8, t float64) uint8 {
+		return clampUint8(float64(a)+(float64(b)-float64(a))*t, 255)
+	}
+
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			t := (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 255
+			newImg.SetNRGBA(x, y, color.NRGBA{
+				R: mix(lo.R, hi.R, t),
+				G: mix(lo.G, hi.G, t),
+				B: mix(lo.B, hi.B, t),
+				A: c.A,
+			})
+		}
+	}
+
+	return newImg
+}
+
+// AddNoise offsets every channel by a pseudo-random amount of up to
+// amount*255 in either direction. The same seed always gives the same
+// noise. Alpha is preserved
+func AddNoise(img image.Image, amount float64, seed int64) image.Image {
+	if amount <= 0 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	rng := rand.New(rand.NewPCG(uint64(seed), 0))
+	offset := func() float64 { return (rng.Float64()*2 - 1) * amount * 255 }
+
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+
+			newImg.SetNRGBA(x, y, color.NRGBA{
+				R: clampUint8(float64(c.R)+offset(), 255),
+				G: clampUint8(float64(c.G)+offset(), 255),
+				B: clampUint8(float64(c.B)+offset(), 255),
+				A: c.A,
+			})
+		}
+	}
+
+	return newImg
+}
+
+// lcdBleed is how much of the other two channels each subpixel stripe keeps,
+// so the stripes don't darken the image too much
+const lcdBleed = 0.25
+
+// LCDGrid renders an upscaled image like a handheld LCD: each cellSize cell
+// takes the color at its top-left and is split into red, green and blue
+// stripes, with a darker one-pixel gap along its right and bottom edges.
+// cellSize should match the upscale factor and be at least 3
+func LCDGrid(img image.Image, cellSize int) image.Image {
+	if cellSize < 3 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	newImg := newNRGBA(width, height)
+
+	stripe := float64(cellSize-1) / 3
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			cx, cy := x-x%cellSize, y-y%cellSize
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+cx, bounds.Min.Y+cy)).(color.NRGBA)
+			dx, dy := x-cx, y-cy
+
+			weights := [3]float64{lcdBleed, lcdBleed, lcdBleed}
+			if dx == cellSize-1 || dy == cellSize-1 {
+				weights = [3]float64{lcdBleed / 2, lcdBleed / 2, lcdBleed / 2}
+			} else {
+				weights[min(int(float64(dx)/stripe), 2)] = 1
+			}
+
+			newImg.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(float64(c.R) * weights[0]),
+				G: uint8(float64(c.G) * weights[1]),
+				B: uint8(float64(c.B) * weights[2]),
+				A: c.A,
+			})
+		}
+	}
+
+	return newImg
+}
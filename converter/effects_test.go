@@ -0,0 +1,186 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestApplyColorKey(t *testing.T) {
+	magenta := color.NRGBA{255, 0, 255, 255}
+	tests := []struct {
+		name        string
+		pixel       color.NRGBA
+		tolerance   int
+		transparent bool
+	}{
+		{"exact match", magenta, 0, true},
+		{"near miss", color.NRGBA{250, 0, 255, 255}, 0, false},
+		{"within tolerance", color.NRGBA{250, 4, 251, 255}, 5, true},
+		{"one channel outside", color.NRGBA{250, 6, 255, 255}, 5, false},
+		{"other color", color.NRGBA{10, 200, 30, 255}, 32, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := ApplyColorKey(solidImage(4, 4, tt.pixel), magenta, tt.tolerance)
+			got := color.NRGBAModel.Convert(img.At(1, 1)).(color.NRGBA)
+			if tt.transparent && got.A != 0 {
+				t.Errorf("got %v, want transparent", got)
+			}
+			if !tt.transparent && got != tt.pixel {
+				t.Errorf("got %v, want %v unchanged", got, tt.pixel)
+			}
+		})
+	}
+}
+
+func TestProcessColorKey(t *testing.T) {
+	config := testConfig()
+	config.ColorKey = "ff00ff"
+	result, err := Process(solidImage(64, 64, color.NRGBA{255, 0, 255, 255}), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Release()
+	if _, _, _, a := result.Image.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("keyed background has alpha %d, want 0", a)
+	}
+}
+
+func TestAddNoise(t *testing.T) {
+	src := gradientImage(16, 16)
+
+	if got := AddNoise(src, 0, 1); got != image.Image(src) {
+		t.Error("amount 0 didn't return the image unchanged")
+	}
+
+	first := encodeTestPNG(t, AddNoise(src, 0.1, 42))
+	if again := encodeTestPNG(t, AddNoise(src, 0.1, 42)); !bytes.Equal(first, again) {
+		t.Error("the same seed gave different noise")
+	}
+	if other := encodeTestPNG(t, AddNoise(src, 0.1, 43)); bytes.Equal(first, other) {
+		t.Error("a different seed gave the same noise")
+	}
+	if bytes.Equal(first, encodeTestPNG(t, src)) {
+		t.Error("noise didn't change the image")
+	}
+}
+
+func TestQuantizeAlpha(t *testing.T) {
+	// Alpha ramps from 0 to 255 across the image
+	src := image.NewNRGBA(image.Rect(0, 0, 16, 1))
+	for x := 0; x < 16; x++ {
+		src.SetNRGBA(x, 0, color.NRGBA{200, 100, 50, uint8(x * 17)})
+	}
+
+	binary := QuantizeAlpha(src, 2)
+	for x := 0; x < 16; x++ {
+		c := color.NRGBAModel.Convert(binary.At(x, 0)).(color.NRGBA)
+		if c.A != 0 && c.A != 255 {
+			t.Errorf("2 levels: alpha at x=%d = %d, want 0 or 255", x, c.A)
+		}
+		if c.A == 255 && (c.R != 200 || c.G != 100 || c.B != 50) {
+			t.Errorf("2 levels: color at x=%d changed to %v", x, c)
+		}
+	}
+
+	// AlphaLevels 0 leaves alpha to the color quantizer, which keeps it
+	config := testConfig()
+	config.Colors = 0
+	passthrough := quantize(src, config)
+	for x := 0; x < 16; x++ {
+		if got, want := color.NRGBAModel.Convert(passthrough.At(x, 0)), src.NRGBAAt(x, 0); got != want {
+			t.Errorf("passthrough: pixel at x=%d = %v, want %v", x, got, want)
+		}
+	}
+}
+
+func TestApplyMask(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	// The mask is smaller than the image and gets stretched over it
+	mask := splitImage(4, 4, 2, color.Black, color.White)
+
+	tests := []struct {
+		name  string
+		img   *image.NRGBA
+		right uint8
+	}{
+		{"opaque image", solidImage(16, 8, red), 255},
+		{"keeps existing alpha", solidImage(16, 8, color.NRGBA{255, 0, 0, 128}), 128},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ApplyMask(tt.img, mask)
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 16; x++ {
+					c := color.NRGBAModel.Convert(got.At(x, y)).(color.NRGBA)
+					want := uint8(0)
+					if x >= 8 {
+						want = tt.right
+					}
+					if c.A != want {
+						t.Fatalf("alpha at (%d, %d) = %d, want %d", x, y, c.A, want)
+					}
+					if c.A != 0 && (c.R != 255 || c.G != 0 || c.B != 0) {
+						t.Fatalf("color at (%d, %d) changed to %v", x, y, c)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPosterizeValue(t *testing.T) {
+	// An orange brightness ramp from black to full value
+	src := image.NewNRGBA(image.Rect(0, 0, 256, 1))
+	for x := 0; x < 256; x++ {
+		src.SetNRGBA(x, 0, color.NRGBA{uint8(x), uint8(x / 2), 0, 255})
+	}
+
+	got := PosterizeValue(src, 4)
+	bands := make(map[uint8]bool)
+	var last uint8
+	for x := 0; x < 256; x++ {
+		c := color.NRGBAModel.Convert(got.At(x, 0)).(color.NRGBA)
+		value := max(c.R, c.G, c.B)
+		bands[value] = true
+		if value < last {
+			t.Fatalf("value drops from %d to %d at x=%d", last, value, x)
+		}
+		last = value
+		// Hue is kept: green stays about half of red
+		if c.R > 0 && (int(c.G)*2 < int(c.R)-2 || int(c.G)*2 > int(c.R)+2) {
+			t.Fatalf("pixel at x=%d = %v, want green half of red", x, c)
+		}
+	}
+	if len(bands) != 4 {
+		t.Errorf("got %d bands of value, want 4", len(bands))
+	}
+}
+
+func TestDuotone(t *testing.T) {
+	shadow := color.NRGBA{30, 10, 80, 255}
+	highlight := color.NRGBA{250, 200, 120, 255}
+	tests := []struct {
+		name  string
+		pixel color.NRGBA
+		want  color.NRGBA
+	}{
+		{"black", color.NRGBA{0, 0, 0, 255}, shadow},
+		{"white", color.NRGBA{255, 255, 255, 255}, highlight},
+		{"mid gray", color.NRGBA{128, 128, 128, 255}, color.NRGBA{140, 105, 100, 255}},
+		{"keeps alpha", color.NRGBA{255, 255, 255, 100}, color.NRGBA{250, 200, 120, 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Duotone(solidImage(2, 2, tt.pixel), shadow, highlight)
+			if c := color.NRGBAModel.Convert(got.At(0, 0)).(color.NRGBA); !nearColor(c, tt.want, 1) {
+				t.Errorf("got %v, want %v", c, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package converter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Modes lists the conversion modes
+func Modes() []string {
+	return []string{ModePixelArt, ModeMosaic}
+}
+
+// DitherModes lists the dithering modes
+func DitherModes() []string {
+	return []string{DitherNone, DitherOrdered, DitherFloydSteinberg, DitherAtkinson}
+}
+
+// SampleModes lists the downscale sample modes
+func SampleModes() []string {
+	return []string{SampleCenter, SampleAverage, SampleMode, SampleLanczos, SampleEdge}
+}
+
+// Formats lists the output formats
+func Formats() []string {
+	return []string{FormatImage, FormatHTML, FormatText}
+}
+
+// CheckEnum returns an error listing the valid values when value is not one
+// of them, e.g. `unknown palette "gameboi"; valid: cga, gameboy, nes, pico8`
+func CheckEnum(kind, value string, valid []string) error {
+	if slices.Contains(valid, value) {
+		return nil
+	}
+	return fmt.Errorf("unknown %s %q; valid: %s", kind, value, strings.Join(valid, ", "))
+}
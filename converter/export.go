@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+)
+
+// Output formats. FormatImage picks the image encoder from the output file
+// extension; the others render the small image, before upscaling, as text
+const (
+	FormatImage = "image"
+	FormatHTML  = "html"
+	FormatText  = "txt"
+)
+
+// convertToText writes img in one of the text formats. The scale becomes the
+// HTML cell size instead of enlarging the pixels, and is unused for text
+func convertToText(img image.Image, config Config) error {
+	log := config.logger()
+
+	cellSize := config.Scale
+	config.Scale = 1
+	config.PixelW, config.PixelH = 0, 0
+	result, err := Process(img, config)
+	if err != nil {
+		return fmt.Errorf("processing image: %w", err)
+	}
+	small := result.Image
+
+	log.Info("Converted", "width", small.Bounds().Dx(), "height", small.Bounds().Dy())
+
+	var out string
+	switch config.Format {
+	case FormatHTML:
+		out, err = ToHTML(small, cellSize)
+	case FormatText:
+		out = ToASCII(small, config.ANSI)
+	}
+	if err != nil {
+		return fmt.Errorf("rendering %s: %w", config.Format, err)
+	}
+
+	if err := writeOutput(config.OutputFile, []byte(out), config.Overwrite); err != nil {
+		return fmt.Errorf("saving %s: %w", config.Format, err)
+	}
+
+	log.Info("Saved", "file", config.OutputFile)
+	return nil
+}
@@ -0,0 +1,183 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultFetchAttempts is how often a URL input is tried when the config
+	// doesn't say
+	defaultFetchAttempts = 3
+	// defaultURLTimeout bounds each attempt at a URL input when the config
+	// doesn't say
+	defaultURLTimeout = 30 * time.Second
+	// fetchDialTimeout bounds connecting to the server
+	fetchDialTimeout = 10 * time.Second
+	// maxFetchRedirects is how many redirects a URL input may follow
+	maxFetchRedirects = 5
+	// fetchBackoff is the wait before the first retry, doubling after each
+	fetchBackoff = 500 * time.Millisecond
+	// maxFetchSize caps the size of a downloaded image
+	maxFetchSize = 64 << 20
+)
+
+// isURL reports whether an input names an http or https URL rather than a
+// file
+func isURL(input string) bool {
+	return strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://")
+}
+
+// newFetchClient returns the client for URL inputs. timeout bounds an
+// attempt from dialing to reading the last byte, so a stalled server can't
+// hang the conversion
+func newFetchClient(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = defaultURLTimeout
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: fetchDialTimeout}).DialContext,
+			TLSHandshakeTimeout:   fetchDialTimeout,
+			ResponseHeaderTimeout: timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+// statusError is a non-2xx response. 5xx responses are worth retrying, 4xx
+// ones are not
+type statusError struct {
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("server returned %d %s", e.status, http.StatusText(e.status))
+}
+
+// fetchURL downloads url, retrying network errors and 5xx responses up to
+// attempts times in total with exponential backoff and jitter. The client
+// times out each attempt and a deadline on ctx, if any, all of them together
+func fetchURL(ctx context.Context, client *http.Client, url string, attempts int, log *slog.Logger) ([]byte, error) {
+	if attempts < 1 {
+		attempts = defaultFetchAttempts
+	}
+
+	backoff := fetchBackoff
+	for attempt := 1; ; attempt++ {
+		data, err := fetchOnce(ctx, client, url)
+		if err == nil {
+			return data, nil
+		}
+
+		statusErr, isStatus := err.(*statusError)
+		if attempt == attempts || ctx.Err() != nil || (isStatus && statusErr.status < 500) {
+			return nil, err
+		}
+
+		// Waiting a random half to all of the backoff keeps concurrent
+		// clients from retrying in lockstep
+		wait := backoff/2 + rand.N(backoff/2+1)
+		log.Warn("Fetch failed, retrying", "url", url, "attempt", attempt, "wait", wait, "error", err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func fetchOnce(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &statusError{status: resp.StatusCode}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFetchSize {
+		return nil, fmt.Errorf("image exceeds %d bytes", maxFetchSize)
+	}
+	return data, nil
+}
+
+// fetchDeadline bounds all attempts at a URL together: each may take the
+// whole per-attempt timeout, plus the longest backoff between them
+func fetchDeadline(timeout time.Duration, attempts int) time.Duration {
+	if timeout <= 0 {
+		timeout = defaultURLTimeout
+	}
+	if attempts < 1 {
+		attempts = defaultFetchAttempts
+	}
+	return time.Duration(attempts)*timeout + fetchBackoff*(1<<(attempts-1)-1)
+}
+
+// loadURLFrames downloads and decodes a URL input, keeping every frame of an
+// animated WebP like loadFrames does for files. The download, retries
+// included, is given up after fetchDeadline
+func loadURLFrames(config Config) ([]Frame, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchDeadline(config.URLTimeout, config.FetchAttempts))
+	defer cancel()
+
+	client := newFetchClient(config.URLTimeout)
+	data, err := fetchURL(ctx, client, config.InputFile, config.FetchAttempts, config.logger())
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch image: %w", err)
+	}
+
+	if http.DetectContentType(data) == "image/webp" {
+		frames, err := DecodeAnimatedWebP(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("could not decode image: %w", err)
+		}
+		return frames, nil
+	}
+
+	// Check the header first, so a small download can't declare an image
+	// that takes gigabytes to decode
+	header, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, decodeError(data, err)
+	}
+	if err := checkDecodePixels(header.Width, header.Height); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, decodeError(data, err)
+	}
+	return []Frame{{Image: img}}, nil
+}
@@ -0,0 +1,148 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer answers with statuses in order, repeating the last one, and
+// counts the requests it got
+func flakyServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		status := statuses[min(n, len(statuses))-1]
+		w.WriteHeader(status)
+		w.Write([]byte("body"))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &requests
+}
+
+func TestFetchURLRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		attempts     int
+		wantErr      bool
+		wantRequests int32
+	}{
+		{"ok", []int{200}, 3, false, 1},
+		{"recovers after 503", []int{503, 200}, 3, false, 2},
+		{"no retry on 404", []int{404}, 3, true, 1},
+		{"gives up on 500", []int{500}, 2, true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, requests := flakyServer(t, tt.statuses...)
+			data, err := fetchURL(context.Background(), ts.Client(), ts.URL, tt.attempts, testConfig().logger())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchURL() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(data) != "body" {
+				t.Errorf("got %q, want %q", data, "body")
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestFetchURLStopsWhenCanceled(t *testing.T) {
+	ts, requests := flakyServer(t, 503)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := fetchURL(ctx, ts.Client(), ts.URL, 10, testConfig().logger())
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) || statusErr.status != 503 {
+		t.Errorf("got error %v, want the last 503", err)
+	}
+	if elapsed := time.Since(start); elapsed > fetchBackoff {
+		t.Errorf("returned after %v, want soon after the context ended", elapsed)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("made %d requests, want 1", got)
+	}
+}
+
+func TestFetchDeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		attempts int
+		want     time.Duration
+	}{
+		{"defaults", 0, 0, 3*defaultURLTimeout + 3*fetchBackoff},
+		{"single attempt", time.Second, 1, time.Second},
+		{"four attempts", time.Second, 4, 4*time.Second + 7*fetchBackoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fetchDeadline(tt.timeout, tt.attempts); got != tt.want {
+				t.Errorf("fetchDeadline(%v, %d) = %v, want %v", tt.timeout, tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFetchClient(t *testing.T) {
+	stall := make(chan struct{})
+	t.Cleanup(func() { close(stall) })
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-stall:
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/hop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	tests := []struct {
+		name    string
+		path    string
+		timeout time.Duration
+		wantErr bool
+	}{
+		{"ok", "/ok", time.Second, false},
+		{"one redirect", "/hop", time.Second, false},
+		{"redirect loop", "/loop", time.Second, true},
+		{"stalled server", "/slow", 50 * time.Millisecond, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			_, err := fetchOnce(context.Background(), newFetchClient(tt.timeout), ts.URL+tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchOnce() error = %v, want error %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > tt.timeout+time.Second {
+				t.Errorf("took %v with a %v timeout", elapsed, tt.timeout)
+			}
+		})
+	}
+
+	if got := newFetchClient(0).Timeout; got != defaultURLTimeout {
+		t.Errorf("timeout 0 gives %v, want the default %v", got, defaultURLTimeout)
+	}
+}
@@ -0,0 +1,176 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// stepImage returns a width x height image that is dark on the left half and
+// light on the right half
+func stepImage(width, height int, dark, light uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := dark
+			if x >= width/2 {
+				v = light
+			}
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+// grayAt returns the red channel at (x, y), for gray test images
+func grayAt(img image.Image, x, y int) uint8 {
+	b := img.Bounds()
+	return color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA).R
+}
+
+func TestSharpen(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    float64
+		darkEdge  func(v uint8) bool
+		lightEdge func(v uint8) bool
+	}{
+		{"off", 0, func(v uint8) bool { return v == 100 }, func(v uint8) bool { return v == 150 }},
+		{"mild", 0.5, func(v uint8) bool { return v < 100 }, func(v uint8) bool { return v > 150 }},
+		{"strong", 4, func(v uint8) bool { return v < 50 }, func(v uint8) bool { return v > 200 }},
+	}
+
+	src := stepImage(8, 4, 100, 150)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := Sharpen(src, tt.amount)
+			if v := grayAt(img, 3, 1); !tt.darkEdge(v) {
+				t.Errorf("dark side of the edge = %d", v)
+			}
+			if v := grayAt(img, 4, 1); !tt.lightEdge(v) {
+				t.Errorf("light side of the edge = %d", v)
+			}
+			// Away from the edge there is nothing to sharpen
+			if v := grayAt(img, 0, 1); v != 100 {
+				t.Errorf("flat area = %d, want 100", v)
+			}
+		})
+	}
+}
+
+func TestSharpenKeepsOffsetBounds(t *testing.T) {
+	src := stepImage(8, 4, 100, 150).SubImage(image.Rect(2, 1, 8, 4))
+	img := Sharpen(src, 1)
+	if got := img.Bounds().Size(); got != (image.Point{6, 3}) {
+		t.Errorf("size = %v, want 6x3", got)
+	}
+}
+
+func TestGaussianBlur(t *testing.T) {
+	tests := []struct {
+		name   string
+		radius float64
+		// spread is how far from the edge the step should still be visible
+		spread int
+	}{
+		{"off", 0, 0},
+		{"small", 0.5, 1},
+		{"large", 2, 3},
+	}
+
+	src := stepImage(16, 4, 0, 200)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := GaussianBlur(src, tt.radius)
+			if tt.spread == 0 {
+				if grayAt(img, 7, 1) != 0 || grayAt(img, 8, 1) != 200 {
+					t.Error("radius 0 changed the image")
+				}
+				return
+			}
+			// The edge is softened on both sides and the values stay ordered
+			if v := grayAt(img, 8-tt.spread, 1); v == 0 {
+				t.Errorf("pixel %d left of the edge is still black", tt.spread)
+			}
+			if v := grayAt(img, 7+tt.spread, 1); v == 200 {
+				t.Errorf("pixel %d right of the edge is still white", tt.spread)
+			}
+			for x := 1; x < 16; x++ {
+				if grayAt(img, x, 1) < grayAt(img, x-1, 1) {
+					t.Fatalf("blur isn't monotonic at x=%d", x)
+				}
+			}
+		})
+	}
+}
+
+func TestGaussianKernel(t *testing.T) {
+	for _, sigma := range []float64{0.5, 1, 2.5} {
+		kernel := gaussianKernel(sigma)
+		var sum float64
+		for _, w := range kernel {
+			sum += w
+		}
+		if sum < 0.999999 || sum > 1.000001 {
+			t.Errorf("sigma %g: kernel sums to %g, want 1", sigma, sum)
+		}
+		if len(kernel)%2 != 1 {
+			t.Errorf("sigma %g: kernel has even length %d", sigma, len(kernel))
+		}
+	}
+}
+
+func TestMedianFilter(t *testing.T) {
+	gray := color.NRGBA{128, 128, 128, 255}
+	speckled := solidImage(9, 9, gray)
+	speckled.Set(4, 4, color.NRGBA{255, 255, 255, 255})
+	speckled.Set(0, 0, color.NRGBA{0, 0, 0, 255})
+
+	tests := []struct {
+		name   string
+		img    image.Image
+		radius int
+		check  func(t *testing.T, img image.Image)
+	}{
+		{"off", speckled, 0, func(t *testing.T, img image.Image) {
+			if v := grayAt(img, 4, 4); v != 255 {
+				t.Errorf("speckle = %d, want it kept", v)
+			}
+		}},
+		{"removes speckles", speckled, 1, func(t *testing.T, img image.Image) {
+			if v := grayAt(img, 4, 4); v != 128 {
+				t.Errorf("center speckle = %d, want 128", v)
+			}
+			if v := grayAt(img, 0, 0); v != 128 {
+				t.Errorf("corner speckle = %d, want 128", v)
+			}
+		}},
+		{"keeps edges", stepImage(8, 8, 20, 220), 2, func(t *testing.T, img image.Image) {
+			if v := grayAt(img, 3, 4); v != 20 {
+				t.Errorf("dark side of the edge = %d, want 20", v)
+			}
+			if v := grayAt(img, 4, 4); v != 220 {
+				t.Errorf("light side of the edge = %d, want 220", v)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check(t, MedianFilter(tt.img, tt.radius))
+		})
+	}
+}
+
+func BenchmarkGaussianBlur(b *testing.B) {
+	src := gradientImage(512, 512)
+	for _, radius := range []float64{1, 4} {
+		b.Run(fmt.Sprintf("radius %g", radius), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				GaussianBlur(src, radius)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+)
+
+// gifMatte is the background partially transparent pixels are composited
+// over, since GIF transparency is all or nothing
+var gifMatte = color.RGBA{255, 255, 255, 255}
+
+// toPaletted converts img to a paletted image for GIF encoding. Images that
+// already use at most 256 colors, as quantized pixel art does, keep their
+// exact colors; anything else is dithered to a median-cut palette. Fully
+// transparent pixels share one transparent palette entry, which the encoder
+// marks as the GIF's transparent index
+func toPaletted(img image.Image) *image.Paletted {
+	bounds := img.Bounds()
+	flat, transparent := flattenAlpha(img)
+
+	pal, ok := exactPalette(flat, 256)
+	if !ok {
+		if transparent {
+			pal = append(MedianCut(flat, 255), color.RGBA{})
+		} else {
+			pal = MedianCut(flat, 256)
+		}
+		dst := image.NewPaletted(bounds, pal)
+		draw.FloydSteinberg.Draw(dst, bounds, flat, bounds.Min)
+		return dst
+	}
+
+	dst := image.NewPaletted(bounds, pal)
+	draw.Draw(dst, bounds, flat, bounds.Min, draw.Src)
+	return dst
+}
+
+// flattenAlpha makes every pixel either opaque or fully transparent:
+// partially transparent pixels are composited over gifMatte and fully
+// transparent ones become color.RGBA{}. It also reports whether any
+// transparent pixels remain
+func flattenAlpha(img image.Image) (*image.RGBA, bool) {
+	bounds := img.Bounds()
+	flat := image.NewRGBA(bounds)
+	transparent := false
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			switch {
+			case a == 0:
+				transparent = true
+			case a == 0xffff:
+				flat.SetRGBA(x, y, color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 255})
+			default:
+				// Premultiplied source over the matte
+				inv := 0xffff - a
+				flat.SetRGBA(x, y, color.RGBA{
+					R: uint8((r + inv*uint32(gifMatte.R)/255) >> 8),
+					G: uint8((g + inv*uint32(gifMatte.G)/255) >> 8),
+					B: uint8((b + inv*uint32(gifMatte.B)/255) >> 8),
+					A: 255,
+				})
+			}
+		}
+	}
+
+	return flat, transparent
+}
+
+// exactPalette collects the distinct colors of img, giving up once there are
+// more than limit
+func exactPalette(img image.Image, limit int) (color.Palette, bool) {
+	bounds := img.Bounds()
+
+	var pal color.Palette
+	seen := make(map[color.RGBA]bool)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			if seen[c] {
+				continue
+			}
+			if len(pal) == limit {
+				return nil, false
+			}
+			seen[c] = true
+			pal = append(pal, c)
+		}
+	}
+
+	return pal, true
+}
+
+// encodeAnimatedGIF writes frames as a looping animated GIF
+func encodeAnimatedGIF(w io.Writer, frames []Frame) error {
+	anim := &gif.GIF{}
+	for _, frame := range frames {
+		anim.Image = append(anim.Image, toPaletted(frame.Image))
+		// GIF delays are in hundredths of a second
+		anim.Delay = append(anim.Delay, int(frame.Delay.Milliseconds()/10))
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+
+	if err := gif.EncodeAll(w, anim); err != nil {
+		return fmt.Errorf("could not encode gif: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func TestGIFTransparencyRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		img  *image.NRGBA
+	}{
+		{"few colors", solidImage(16, 16, color.NRGBA{200, 40, 40, 255})},
+		// Over 256 colors, so the palette comes from median cut
+		{"many colors", gradientImage(32, 32)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Clear the left half and make one pixel half transparent
+			img := tt.img
+			bounds := img.Bounds()
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				for x := bounds.Min.X; x < bounds.Dx()/2; x++ {
+					img.SetNRGBA(x, y, color.NRGBA{})
+				}
+			}
+			img.SetNRGBA(bounds.Max.X-1, 0, color.NRGBA{0, 0, 0, 128})
+
+			data, err := EncodeAs(img, ".gif")
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := gif.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, _, a := decoded.At(0, 0).RGBA(); a != 0 {
+				t.Errorf("transparent pixel has alpha %d, want 0", a)
+			}
+			if _, _, _, a := decoded.At(bounds.Max.X-1, bounds.Max.Y-1).RGBA(); a != 0xffff {
+				t.Errorf("opaque pixel has alpha %d, want opaque", a)
+			}
+			// Half transparent black over the white matte comes out opaque gray
+			c := color.NRGBAModel.Convert(decoded.At(bounds.Max.X-1, 0)).(color.NRGBA)
+			if c.A != 255 || !nearColor(c, color.NRGBA{127, 127, 127, 255}, 24) {
+				t.Errorf("half transparent pixel = %v, want gray over the matte", c)
+			}
+		})
+	}
+}
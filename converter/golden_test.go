@@ -0,0 +1,140 @@
+package converter
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// goldenTolerance is how far a channel may drift from the golden before the
+// test fails, to absorb float rounding differences between platforms
+const goldenTolerance = 2
+
+// loadFixture decodes a fixture input from testdata
+func loadFixture(t *testing.T, name string) image.Image {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// checkGolden compares img with testdata/golden/name.png, or rewrites the
+// golden when -update is set
+func checkGolden(t *testing.T, name string, img image.Image) {
+	t.Helper()
+	var got bytes.Buffer
+	if err := png.Encode(&got, img); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join("testdata", "golden", name+".png")
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run go test -update to create it", err)
+	}
+	if bytes.Equal(got.Bytes(), want) {
+		return
+	}
+
+	// The encodings differ, so compare pixels within the tolerance
+	wantImg, err := png.Decode(bytes.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Size() != wantImg.Bounds().Size() {
+		t.Fatalf("size = %v, golden is %v", img.Bounds().Size(), wantImg.Bounds().Size())
+	}
+	gb, wb := img.Bounds(), wantImg.Bounds()
+	for y := 0; y < gb.Dy(); y++ {
+		for x := 0; x < gb.Dx(); x++ {
+			g := color.NRGBAModel.Convert(img.At(gb.Min.X+x, gb.Min.Y+y)).(color.NRGBA)
+			w := color.NRGBAModel.Convert(wantImg.At(wb.Min.X+x, wb.Min.Y+y)).(color.NRGBA)
+			if channelDiff(g.R, w.R) > goldenTolerance || channelDiff(g.G, w.G) > goldenTolerance ||
+				channelDiff(g.B, w.B) > goldenTolerance || channelDiff(g.A, w.A) > goldenTolerance {
+				t.Fatalf("pixel (%d,%d) = %v, golden has %v", x, y, g, w)
+			}
+		}
+	}
+}
+
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(img image.Image) (image.Image, error)
+	}{
+		{"downscale", func(img image.Image) (image.Image, error) {
+			return Downscale(img, 24), nil
+		}},
+		{"quantize", func(img image.Image) (image.Image, error) {
+			return QuantizeColors(img, 8), nil
+		}},
+		{"palette", func(img image.Image) (image.Image, error) {
+			palette, _ := NamedPalette("gameboy")
+			return QuantizeToPalette(img, palette), nil
+		}},
+		{"pipeline", processGolden(func(c *Config) {})},
+		{"mosaic", processGolden(func(c *Config) { c.Mode = ModeMosaic })},
+		{"floyd-steinberg", processGolden(func(c *Config) { c.Dither = DitherFloydSteinberg; c.Colors = 4 })},
+		{"ordered", processGolden(func(c *Config) { c.Dither = DitherOrdered; c.Colors = 4 })},
+		{"bw", processGolden(func(c *Config) { c.BlackWhite = true })},
+	}
+
+	input := loadFixture(t, "fixture.png")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := tt.run(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.name, img)
+		})
+	}
+}
+
+// goldenConfig is DefaultConfig with a small grid and logging silenced
+func goldenConfig() Config {
+	config := DefaultConfig()
+	config.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	config.PixelSize = 16
+	config.Scale = 2
+	return config
+}
+
+// processGolden runs the full pipeline with goldenConfig changed by modify
+func processGolden(modify func(*Config)) func(image.Image) (image.Image, error) {
+	return func(img image.Image) (image.Image, error) {
+		config := goldenConfig()
+		modify(&config)
+		result, err := Process(img, config)
+		if err != nil {
+			return nil, err
+		}
+		return result.Image, nil
+	}
+}
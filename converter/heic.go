@@ -0,0 +1,23 @@
+//go:build heic
+
+package converter
+
+import (
+	"image"
+
+	"github.com/jdeng/goheif"
+)
+
+// Builds with -tags heic decode HEIC and HEVC-coded HEIF through libheif's
+// decoder via cgo. The module has to be added first:
+//
+//	go get github.com/jdeng/goheif
+//	go build -tags heic ./...
+func init() {
+	heicDecoder = true
+	for brand, format := range heifBrands {
+		if format != "AVIF" {
+			image.RegisterFormat("heic", "????ftyp"+brand, goheif.Decode, goheif.DecodeConfig)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// heifBrands maps the ftyp brands of HEIF-based files to the name users know
+// them by
+var heifBrands = map[string]string{
+	"heic": "HEIC",
+	"heix": "HEIC",
+	"heim": "HEIC",
+	"heis": "HEIC",
+	"hevc": "HEIC",
+	"mif1": "HEIF",
+	"msf1": "HEIF",
+	"avif": "AVIF",
+	"avis": "AVIF",
+}
+
+// heicDecoder is set by heic.go in builds with the heic tag, which register a
+// HEIC decoder with image.Decode
+var heicDecoder bool
+
+// HEIFFormat reports whether head, the start of a file, is a HEIC, HEIF or
+// AVIF image and which
+func HEIFFormat(head []byte) (string, bool) {
+	if len(head) < 12 || string(head[4:8]) != "ftyp" {
+		return "", false
+	}
+	format, ok := heifBrands[string(head[8:12])]
+	return format, ok
+}
+
+// CanDecodeHEIF reports whether this build decodes format, as returned by
+// HEIFFormat. HEIC and HEIF need the heic build tag; AVIF is never decoded
+func CanDecodeHEIF(format string) bool {
+	return heicDecoder && format != "AVIF"
+}
+
+// HEIFError explains why format, as returned by HEIFFormat, can't be decoded
+// and what to do about it, instead of image.Decode's "unknown format"
+func HEIFError(format string) error {
+	if format == "AVIF" {
+		return fmt.Errorf("AVIF input is not supported; convert the image to PNG or JPEG first")
+	}
+	return fmt.Errorf("%s input requires the heic build tag; rebuild with -tags heic or convert the image to PNG or JPEG first", format)
+}
+
+// decodeError wraps a decode failure, explaining HEIF-based input this build
+// has no decoder for
+func decodeError(head []byte, err error) error {
+	if format, ok := HEIFFormat(head); ok && errors.Is(err, image.ErrFormat) {
+		return HEIFError(format)
+	}
+	return fmt.Errorf("could not decode image: %w", err)
+}
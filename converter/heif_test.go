@@ -0,0 +1,32 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestDecodeErrorHEIF(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"heic", []byte("\x00\x00\x00\x18ftypheic"), "HEIC input requires the heic build tag"},
+		{"heif", []byte("\x00\x00\x00\x18ftypmif1"), "HEIF input requires the heic build tag"},
+		{"avif", []byte("\x00\x00\x00\x18ftypavif"), "AVIF input is not supported"},
+		{"unknown", []byte("not an image at all"), "could not decode image"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if format, ok := HEIFFormat(tt.head); ok && CanDecodeHEIF(format) {
+				t.Skip("decoded in builds with the heic tag")
+			}
+			_, _, err := image.Decode(bytes.NewReader(tt.head))
+			if got := decodeError(tt.head, err).Error(); !strings.Contains(got, tt.want) {
+				t.Errorf("error = %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
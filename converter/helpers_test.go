@@ -0,0 +1,59 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// gradientImage returns a width x height image with red rising across and
+// green rising down, so it has many distinct colors
+func gradientImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(x * 255 / max(width-1, 1)),
+				G: uint8(y * 255 / max(height-1, 1)),
+				B: uint8((x + y) * 255 / max(width+height-2, 1)),
+				A: 255,
+			})
+		}
+	}
+	return img
+}
+
+// solidImage returns a width x height image filled with c
+func solidImage(width, height int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// testConfig is DefaultConfig with a small grid, for fast tests, and logging
+// silenced
+func testConfig() Config {
+	config := DefaultConfig()
+	config.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	config.PixelSize = 16
+	config.Scale = 2
+	return config
+}
+
+// encodeTestPNG encodes img as PNG, for comparing images byte for byte
+func encodeTestPNG(t testing.TB, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
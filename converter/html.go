@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// MaxHTMLPixels caps the image size ToHTML accepts, since every pixel
+// becomes markup
+const MaxHTMLPixels = 128 * 128
+
+// ToHTML renders img as a standalone HTML page holding a table with one
+// cellSize x cellSize cell per pixel. Runs of equal pixels in a row share a
+// cell via colspan, and transparent pixels are left unpainted
+func ToHTML(img image.Image, cellSize int) (string, error) {
+	bounds := img.Bounds()
+	if n := bounds.Dx() * bounds.Dy(); n > MaxHTMLPixels {
+		return "", fmt.Errorf("image has %d pixels, HTML output is limited to %d", n, MaxHTMLPixels)
+	}
+	if cellSize <= 0 {
+		return "", fmt.Errorf("cell size must be positive, got %d", cellSize)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>pixgrid</title>\n<style>\n")
+	// A fixed layout with explicit columns keeps colspan runs from
+	// collapsing columns that have no single cell of their own
+	fmt.Fprintf(&sb, "table.pixgrid { border-collapse: collapse; border-spacing: 0; table-layout: fixed; width: %dpx; }\n", bounds.Dx()*cellSize)
+	fmt.Fprintf(&sb, "table.pixgrid col { width: %dpx; }\n", cellSize)
+	fmt.Fprintf(&sb, "table.pixgrid td { padding: 0; height: %dpx; }\n", cellSize)
+	sb.WriteString("</style>\n</head>\n<body>\n<table class=\"pixgrid\">\n")
+	fmt.Fprintf(&sb, "<colgroup><col span=\"%d\"></colgroup>\n", bounds.Dx())
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		sb.WriteString("<tr>")
+		for x := bounds.Min.X; x < bounds.Max.X; {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			run := 1
+			for x+run < bounds.Max.X && color.NRGBAModel.Convert(img.At(x+run, y)).(color.NRGBA) == c {
+				run++
+			}
+
+			sb.WriteString("<td")
+			if run > 1 {
+				fmt.Fprintf(&sb, " colspan=\"%d\"", run)
+			}
+			switch {
+			case c.A == 255:
+				fmt.Fprintf(&sb, " style=\"background:#%02x%02x%02x\"", c.R, c.G, c.B)
+			case c.A > 0:
+				fmt.Fprintf(&sb, " style=\"background:rgba(%d,%d,%d,%.3g)\"", c.R, c.G, c.B, float64(c.A)/255)
+			}
+			sb.WriteString("></td>")
+
+			x += run
+		}
+		sb.WriteString("</tr>\n")
+	}
+
+	sb.WriteString("</table>\n</body>\n</html>\n")
+	return sb.String(), nil
+}
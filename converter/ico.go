@@ -0,0 +1,246 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+func init() {
+	image.RegisterFormat("ico", "\x00\x00\x01\x00", decodeICO, decodeICOConfig)
+}
+
+// icoEntry is one image in an ICO directory
+type icoEntry struct {
+	Width, Height int
+	BitCount      int
+	Size, Offset  int
+}
+
+// DecodeICO decodes every image of an ICO file, in directory order. Images
+// may be stored as PNG or as a headerless BMP with a transparency mask
+func DecodeICO(r io.Reader) ([]image.Image, error) {
+	data, entries, err := readICO(r)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]image.Image, len(entries))
+	for i, entry := range entries {
+		if images[i], err = decodeICOEntry(data, entry); err != nil {
+			return nil, fmt.Errorf("icon %d: %w", i, err)
+		}
+	}
+	return images, nil
+}
+
+// decodeICO decodes the largest image of an ICO file, preferring the deepest
+// color among equal sizes, so image.Decode gets the most detailed one
+func decodeICO(r io.Reader) (image.Image, error) {
+	data, entries, err := readICO(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeICOEntry(data, entries[largestICOEntry(entries)])
+}
+
+func decodeICOConfig(r io.Reader) (image.Config, error) {
+	data, entries, err := readICO(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	entry := entries[largestICOEntry(entries)]
+	payload := data[entry.Offset : entry.Offset+entry.Size]
+	if bytes.HasPrefix(payload, pngSignature) {
+		return icoPNGConfig(payload, entry)
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: entry.Width, Height: entry.Height}, nil
+}
+
+func largestICOEntry(entries []icoEntry) int {
+	best := 0
+	for i, entry := range entries {
+		area, bestArea := entry.Width*entry.Height, entries[best].Width*entries[best].Height
+		if area > bestArea || (area == bestArea && entry.BitCount > entries[best].BitCount) {
+			best = i
+		}
+	}
+	return best
+}
+
+// readICO reads the whole file and parses its directory
+func readICO(r io.Reader) ([]byte, []icoEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) < 6 || binary.LittleEndian.Uint16(data[2:]) != 1 {
+		return nil, nil, fmt.Errorf("ico: not an icon file")
+	}
+
+	count := int(binary.LittleEndian.Uint16(data[4:]))
+	if count == 0 {
+		return nil, nil, fmt.Errorf("ico: no images")
+	}
+	if len(data) < 6+16*count {
+		return nil, nil, fmt.Errorf("ico: truncated directory")
+	}
+
+	entries := make([]icoEntry, count)
+	for i := range entries {
+		e := data[6+16*i:]
+		// A stored size of 0 means 256
+		entries[i] = icoEntry{
+			Width:    int(e[0]),
+			Height:   int(e[1]),
+			BitCount: int(binary.LittleEndian.Uint16(e[6:])),
+			Size:     int(binary.LittleEndian.Uint32(e[8:])),
+			Offset:   int(binary.LittleEndian.Uint32(e[12:])),
+		}
+		if entries[i].Width == 0 {
+			entries[i].Width = 256
+		}
+		if entries[i].Height == 0 {
+			entries[i].Height = 256
+		}
+		if entries[i].Offset+entries[i].Size > len(data) || entries[i].Size < 0 {
+			return nil, nil, fmt.Errorf("ico: image %d out of bounds", i)
+		}
+	}
+	return data, entries, nil
+}
+
+func decodeICOEntry(data []byte, entry icoEntry) (image.Image, error) {
+	payload := data[entry.Offset : entry.Offset+entry.Size]
+	if bytes.HasPrefix(payload, pngSignature) {
+		if _, err := icoPNGConfig(payload, entry); err != nil {
+			return nil, err
+		}
+		return png.Decode(bytes.NewReader(payload))
+	}
+	return decodeICOBitmap(payload)
+}
+
+// icoPNGConfig reads the header of an embedded PNG and checks it against its
+// directory entry and maxDecodePixels before anything is allocated for it. A
+// directory size of 256 is stored as 0 and stands for 256 or more
+func icoPNGConfig(payload []byte, entry icoEntry) (image.Config, error) {
+	config, err := png.DecodeConfig(bytes.NewReader(payload))
+	if err != nil {
+		return image.Config{}, err
+	}
+	if !icoSizeMatches(config.Width, entry.Width) || !icoSizeMatches(config.Height, entry.Height) {
+		return image.Config{}, fmt.Errorf("ico: png of %dx%d does not match its %dx%d entry",
+			config.Width, config.Height, entry.Width, entry.Height)
+	}
+	if err := checkDecodePixels(config.Width, config.Height); err != nil {
+		return image.Config{}, fmt.Errorf("ico: %w", err)
+	}
+	return config, nil
+}
+
+func icoSizeMatches(actual, stored int) bool {
+	return actual == stored || (stored == 256 && actual > 256)
+}
+
+// decodeICOBitmap decodes a BITMAPINFOHEADER image as stored in icons: rows
+// bottom-up, the height doubled to cover the 1-bit AND mask that follows the
+// color data and marks transparent pixels
+func decodeICOBitmap(b []byte) (image.Image, error) {
+	if len(b) < 40 || binary.LittleEndian.Uint32(b) < 40 {
+		return nil, fmt.Errorf("ico: unsupported bitmap header")
+	}
+
+	headerSize := int(binary.LittleEndian.Uint32(b))
+	width := int(int32(binary.LittleEndian.Uint32(b[4:])))
+	height := int(int32(binary.LittleEndian.Uint32(b[8:]))) / 2
+	bitCount := int(binary.LittleEndian.Uint16(b[14:]))
+	compression := binary.LittleEndian.Uint32(b[16:])
+	colorsUsed := int(binary.LittleEndian.Uint32(b[32:]))
+
+	if width <= 0 || height <= 0 || width > 1024 || height > 1024 {
+		return nil, fmt.Errorf("ico: invalid bitmap size %dx%d", width, height)
+	}
+	// BI_BITFIELDS only appears with 32-bit BGRA masks in icons
+	if compression != 0 && !(compression == 3 && bitCount == 32) {
+		return nil, fmt.Errorf("ico: unsupported bitmap compression %d", compression)
+	}
+
+	var palette []color.NRGBA
+	offset := headerSize
+	if compression == 3 && headerSize == 40 {
+		offset += 12
+	}
+	switch bitCount {
+	case 1, 4, 8:
+		if colorsUsed == 0 {
+			colorsUsed = 1 << bitCount
+		}
+		if len(b) < offset+4*colorsUsed {
+			return nil, fmt.Errorf("ico: truncated palette")
+		}
+		palette = make([]color.NRGBA, colorsUsed)
+		for i := range palette {
+			p := b[offset+4*i:]
+			palette[i] = color.NRGBA{R: p[2], G: p[1], B: p[0], A: 255}
+		}
+		offset += 4 * colorsUsed
+	case 24, 32:
+	default:
+		return nil, fmt.Errorf("ico: unsupported bit depth %d", bitCount)
+	}
+
+	stride := (width*bitCount + 31) / 32 * 4
+	maskStride := (width + 31) / 32 * 4
+	maskOffset := offset + stride*height
+	hasMask := len(b) >= maskOffset+maskStride*height
+	if len(b) < maskOffset {
+		return nil, fmt.Errorf("ico: truncated bitmap")
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	anyAlpha := false
+	for y := 0; y < height; y++ {
+		row := b[offset+(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bitCount {
+			case 32:
+				p := row[4*x:]
+				c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: p[3]}
+				anyAlpha = anyAlpha || p[3] != 0
+			case 24:
+				p := row[3*x:]
+				c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: 255}
+			default:
+				bit := x * bitCount
+				index := int(row[bit/8]>>(8-bitCount-bit%8)) & (1<<bitCount - 1)
+				if index < len(palette) {
+					c = palette[index]
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// 32-bit icons carry their own alpha; older ones rely on the mask, and
+	// 32-bit ones with an all-zero alpha channel do too
+	if bitCount == 32 && anyAlpha {
+		return img, nil
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := img.NRGBAAt(x, y)
+			c.A = 255
+			if hasMask && b[maskOffset+(height-1-y)*maskStride+x/8]&(0x80>>(x%8)) != 0 {
+				c.A = 0
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// icoEntryPNG is one PNG image of a test ICO and the size its directory
+// entry claims
+type icoEntryPNG struct {
+	img           image.Image
+	width, height int
+}
+
+// pngICO builds an ICO file storing each entry as PNG
+func pngICO(t *testing.T, entries ...icoEntryPNG) []byte {
+	t.Helper()
+	header := make([]byte, 6+16*len(entries))
+	binary.LittleEndian.PutUint16(header[2:], 1)
+	binary.LittleEndian.PutUint16(header[4:], uint16(len(entries)))
+
+	var payloads bytes.Buffer
+	for i, entry := range entries {
+		payload := encodeTestPNG(t, entry.img)
+		e := header[6+16*i:]
+		e[0], e[1] = uint8(entry.width), uint8(entry.height)
+		binary.LittleEndian.PutUint16(e[6:], 32)
+		binary.LittleEndian.PutUint32(e[8:], uint32(len(payload)))
+		binary.LittleEndian.PutUint32(e[12:], uint32(len(header)+payloads.Len()))
+		payloads.Write(payload)
+	}
+	return append(header, payloads.Bytes()...)
+}
+
+func TestDecodeICOPicksLargest(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	data := pngICO(t,
+		icoEntryPNG{solidImage(16, 16, color.Black), 16, 16},
+		icoEntryPNG{solidImage(48, 48, red), 48, 48},
+		icoEntryPNG{solidImage(32, 32, color.White), 32, 32},
+	)
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "ico" || config.Width != 48 || config.Height != 48 {
+		t.Errorf("DecodeConfig = %s %dx%d, want ico 48x48", format, config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(48, 48) {
+		t.Errorf("decoded size = %v, want 48x48", got)
+	}
+	if got := color.NRGBAModel.Convert(img.At(0, 0)); got != red {
+		t.Errorf("pixel = %v, want %v", got, red)
+	}
+}
+
+func TestDecodeICORejectsMismatchedPNG(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry icoEntryPNG
+	}{
+		{"larger than entry", icoEntryPNG{solidImage(64, 64, color.Black), 16, 16}},
+		{"smaller than entry", icoEntryPNG{solidImage(8, 8, color.Black), 16, 16}},
+		{"under 256 for a 256 entry", icoEntryPNG{solidImage(64, 64, color.Black), 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := pngICO(t, tt.entry)
+			if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "does not match") {
+				t.Errorf("DecodeConfig error = %v, want a size mismatch", err)
+			}
+			if _, _, err := image.Decode(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "does not match") {
+				t.Errorf("Decode error = %v, want a size mismatch", err)
+			}
+		})
+	}
+}
+
+func TestDecodeICORejectsOversizedPNG(t *testing.T) {
+	data := pngICO(t, icoEntryPNG{solidImage(1, 1, color.Black), 0, 0})
+	// Patch the PNG header to claim 10000x10000 and fix up its checksum
+	ihdr := data[6+16+8:]
+	binary.BigEndian.PutUint32(ihdr[8:], 10000)
+	binary.BigEndian.PutUint32(ihdr[12:], 10000)
+	binary.BigEndian.PutUint32(ihdr[21:], crc32.ChecksumIEEE(ihdr[4:21]))
+
+	if _, _, err := image.Decode(bytes.NewReader(data)); err == nil || !strings.Contains(err.Error(), "over the limit") {
+		t.Errorf("Decode error = %v, want the pixel limit", err)
+	}
+}
@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+)
+
+// jpegQuality is the quality JPEG output is written at without a size limit
+const jpegQuality = 95
+
+// EncodeJPEGUnderSize encodes img as a JPEG of at most maxBytes bytes,
+// binary searching for the highest quality that fits. It fails when even
+// quality 1 is too large
+func EncodeJPEGUnderSize(img image.Image, maxBytes int) ([]byte, error) {
+	encode := func(quality int) ([]byte, error) {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, fmt.Errorf("could not encode image: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+
+	best, err := encode(1)
+	if err != nil {
+		return nil, err
+	}
+	if len(best) > maxBytes {
+		return nil, fmt.Errorf("JPEG takes %d bytes even at quality 1, over the %d byte limit", len(best), maxBytes)
+	}
+
+	// Size grows with quality, so the search keeps lo fitting and everything
+	// above hi too large
+	lo, hi := 1, 100
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		data, err := encode(mid)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) <= maxBytes {
+			lo, best = mid, data
+		} else {
+			hi = mid - 1
+		}
+	}
+	return best, nil
+}
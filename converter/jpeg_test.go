@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"bytes"
+	"image/jpeg"
+	"testing"
+)
+
+func TestEncodeJPEGUnderSize(t *testing.T) {
+	img := gradientImage(128, 128)
+	best := encodeJPEGQuality(t, 100)
+	worst := encodeJPEGQuality(t, 1)
+
+	tests := []struct {
+		name     string
+		maxBytes int
+		wantErr  bool
+	}{
+		{"roomy", best * 2, false},
+		{"exact best", best, false},
+		{"between", (best + worst) / 2, false},
+		{"just worst", worst, false},
+		{"too small", worst - 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := EncodeJPEGUnderSize(img, tt.maxBytes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EncodeJPEGUnderSize() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(data) > tt.maxBytes {
+				t.Errorf("got %d bytes, over the %d limit", len(data), tt.maxBytes)
+			}
+			if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+				t.Errorf("output doesn't decode: %v", err)
+			}
+		})
+	}
+}
+
+// encodeJPEGQuality returns the size of the gradient test image as a JPEG of
+// the given quality
+func encodeJPEGQuality(t *testing.T, quality int) int {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, gradientImage(128, 128), &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Len()
+}
+
+func TestEncodeImageMaxFileSize(t *testing.T) {
+	tests := []struct {
+		filename string
+		wantErr  bool
+	}{
+		{"out.jpg", false},
+		{"out.jpeg", false},
+		{"out.png", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			_, err := encodeImage(tt.filename, gradientImage(64, 64), 1<<20)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("encodeImage() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package converter
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// lanczosTap is one source sample contributing to an output pixel
+type lanczosTap struct {
+	index  int
+	weight float64
+}
+
+// DownscaleLanczos resizes to targetWidth with a separable Lanczos-3 filter,
+// keeping the aspect ratio. It gives the cleanest shrink of photographic
+// sources but is much slower than the other sample modes, so both passes are
+// split across CPUs. Samples past the edges are clamped
+func DownscaleLanczos(img image.Image, targetWidth int) image.Image {
+	bounds := img.Bounds()
+	return resizeLanczos(img, targetWidth, proportionalSize(bounds.Dy(), targetWidth, bounds.Dx()))
+}
+
+// resizeLanczos resamples img to targetWidth x targetHeight with a Lanczos-3
+// filter
+func resizeLanczos(img image.Image, targetWidth, targetHeight int) image.Image {
+	bounds := img.Bounds()
+	origWidth := bounds.Dx()
+	origHeight := bounds.Dy()
+
+	// Premultiplied RGBA as floats, row by row
+	src := make([]float64, origWidth*origHeight*4)
+	parallelRows(origHeight, func(y int) {
+		for x := 0; x < origWidth; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			i := (y*origWidth + x) * 4
+			src[i], src[i+1], src[i+2], src[i+3] = float64(r>>8), float64(g>>8), float64(b>>8), float64(a>>8)
+		}
+	})
+
+	// Horizontal pass: origHeight rows of targetWidth
+	xTaps := lanczosTaps(origWidth, targetWidth)
+	tmp := make([]float64, targetWidth*origHeight*4)
+	parallelRows(origHeight, func(y int) {
+		for x, taps := range xTaps {
+			o := (y*targetWidth + x) * 4
+			for _, t := range taps {
+				i := (y*origWidth + t.index) * 4
+				for c := 0; c < 4; c++ {
+					tmp[o+c] += src[i+c] * t.weight
+				}
+			}
+		}
+	})
+
+	// Vertical pass straight into the output
+	yTaps := lanczosTaps(origHeight, targetHeight)
+	newImg := newRGBA(targetWidth, targetHeight)
+	parallelRows(targetHeight, func(y int) {
+		for x := 0; x < targetWidth; x++ {
+			var sum [4]float64
+			for _, t := range yTaps[y] {
+				i := (t.index*targetWidth + x) * 4
+				for c := 0; c < 4; c++ {
+					sum[c] += tmp[i+c] * t.weight
+				}
+			}
+
+			// Lanczos overshoots; premultiplied channels must stay within alpha
+			a := clampUint8(sum[3], 255)
+			o := newImg.PixOffset(x, y)
+			newImg.Pix[o] = clampUint8(sum[0], float64(a))
+			newImg.Pix[o+1] = clampUint8(sum[1], float64(a))
+			newImg.Pix[o+2] = clampUint8(sum[2], float64(a))
+			newImg.Pix[o+3] = a
+		}
+	})
+
+	return newImg
+}
+
+// lanczosTaps computes the normalized filter taps for resampling src samples
+// to dst. When shrinking, the kernel is stretched by the scale factor so it
+// also acts as the low-pass filter
+func lanczosTaps(src, dst int) [][]lanczosTap {
+	scale := float64(src) / float64(dst)
+	stretch := math.Max(scale, 1)
+	support := 3 * stretch
+
+	taps := make([][]lanczosTap, dst)
+	for i := range taps {
+		center := (float64(i) + 0.5) * scale
+		lo := int(math.Floor(center - support))
+		hi := int(math.Ceil(center + support))
+
+		var sum float64
+		for j := lo; j <= hi; j++ {
+			w := lanczos3((float64(j) + 0.5 - center) / stretch)
+			if w == 0 {
+				continue
+			}
+			taps[i] = append(taps[i], lanczosTap{index: clampInt(j, 0, src-1), weight: w})
+			sum += w
+		}
+		for k := range taps[i] {
+			taps[i][k].weight /= sum
+		}
+	}
+
+	return taps
+}
+
+func lanczos3(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	if x <= -3 || x >= 3 {
+		return 0
+	}
+	px := math.Pi * x
+	return 3 * math.Sin(px) * math.Sin(px/3) / (px * px)
+}
+
+// parallelRows calls fn for every row in [0, n), spreading contiguous ranges
+// of rows over one goroutine per CPU
+func parallelRows(n int, fn func(y int)) {
+	workers := min(runtime.GOMAXPROCS(0), n)
+	if workers <= 1 {
+		for y := 0; y < n; y++ {
+			fn(y)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	chunk := (n + workers - 1) / workers
+	for start := 0; start < n; start += chunk {
+		end := min(start+chunk, n)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := start; y < end; y++ {
+				fn(y)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,107 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestDownscaleLanczos(t *testing.T) {
+	red := color.NRGBA{200, 40, 40, 255}
+	tests := []struct {
+		name          string
+		img           image.Image
+		target        int
+		width, height int
+	}{
+		{"halve", solidImage(64, 32, red), 32, 32, 16},
+		{"odd ratio", solidImage(100, 75, red), 30, 30, 23},
+		{"enlarge", solidImage(8, 8, red), 16, 16, 16},
+		{"offset bounds", solidImage(40, 40, red).SubImage(image.Rect(10, 10, 30, 20)), 10, 10, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DownscaleLanczos(tt.img, tt.target)
+			if size := got.Bounds().Size(); size != image.Pt(tt.width, tt.height) {
+				t.Fatalf("size = %v, want %dx%d", size, tt.width, tt.height)
+			}
+			// A flat color stays flat; the filter's weights sum to one
+			for _, p := range []image.Point{{0, 0}, {tt.width / 2, tt.height / 2}, {tt.width - 1, tt.height - 1}} {
+				c := color.NRGBAModel.Convert(got.At(p.X, p.Y)).(color.NRGBA)
+				if !nearColor(c, red, 1) {
+					t.Errorf("pixel %v = %v, want %v", p, c, red)
+				}
+			}
+		})
+	}
+}
+
+func TestLanczosTapsNormalized(t *testing.T) {
+	for _, sizes := range [][2]int{{100, 30}, {64, 32}, {7, 3}, {8, 16}} {
+		for i, taps := range lanczosTaps(sizes[0], sizes[1]) {
+			var sum float64
+			for _, tap := range taps {
+				if tap.index < 0 || tap.index >= sizes[0] {
+					t.Fatalf("%d to %d: output %d samples index %d", sizes[0], sizes[1], i, tap.index)
+				}
+				sum += tap.weight
+			}
+			if sum < 0.999999 || sum > 1.000001 {
+				t.Errorf("%d to %d: output %d weights sum to %g", sizes[0], sizes[1], i, sum)
+			}
+		}
+	}
+}
+
+func TestDownscaleLanczosSmoothsStep(t *testing.T) {
+	got := DownscaleLanczos(stepImage(64, 8, 0, 200), 16)
+	left, right := grayAt(got, 0, 1), grayAt(got, 15, 1)
+	if left > 5 || right < 195 {
+		t.Errorf("flat ends = %d and %d, want about 0 and 200", left, right)
+	}
+	if mid := grayAt(got, 8, 1); mid == 0 || mid == 200 {
+		t.Errorf("pixel at the edge = %d, want a blend", mid)
+	}
+}
+
+// rowContrast returns the spread between the darkest and lightest pixel of
+// row y, skipping margin pixels at either end
+func rowContrast(img image.Image, y, margin int) int {
+	lo, hi := 255, 0
+	for x := margin; x < img.Bounds().Dx()-margin; x++ {
+		v := int(grayAt(img, x, y))
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	return hi - lo
+}
+
+func TestDownscaleLanczosSharperThanAverage(t *testing.T) {
+	// Fine gray stripes, 9 source pixels per period, shrunk to a third
+	img := image.NewNRGBA(image.Rect(0, 0, 96, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 96; x++ {
+			v := uint8(127.5 + 127.5*math.Sin(2*math.Pi*float64(x)/9))
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+
+	lanczos := rowContrast(DownscaleSample(img, 32, SampleLanczos), 1, 4)
+	average := rowContrast(DownscaleSample(img, 32, SampleAverage), 1, 4)
+	if lanczos <= average {
+		t.Errorf("lanczos contrast = %d, want more than average's %d", lanczos, average)
+	}
+}
+
+func BenchmarkDownscaleSample(b *testing.B) {
+	src := gradientImage(512, 512)
+	for _, sample := range []string{SampleAverage, SampleLanczos} {
+		b.Run(sample, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				release(DownscaleSample(src, 64, sample))
+			}
+		})
+	}
+}
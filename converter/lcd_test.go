@@ -0,0 +1,46 @@
+package converter
+
+import (
+	"image/color"
+	"slices"
+	"testing"
+)
+
+func TestLCDGrid(t *testing.T) {
+	src := solidImage(2, 2, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+	got := LCDGrid(UpscaleNearestNeighbor(src, 3), 3)
+	if b := got.Bounds(); b.Dx() != 6 || b.Dy() != 6 {
+		t.Fatalf("got %dx%d, want 6x6", b.Dx(), b.Dy())
+	}
+	// Each cell repeats the same subpixel layout
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			if got.At(x, y) != got.At(x+3, y+3) {
+				t.Errorf("cell pixel (%d, %d) differs between cells", x, y)
+			}
+		}
+	}
+}
+
+func TestLCDRunsBeforePixelAspect(t *testing.T) {
+	config := testConfig()
+	config.Scale = 3
+	config.LCD = true
+	config.PARWidth, config.PARHeight = 2, 1
+
+	result, err := Process(gradientImage(32, 32), config)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	var names []string
+	for _, stage := range result.Stages {
+		names = append(names, stage.Name)
+	}
+	lcd, par := slices.Index(names, "lcd"), slices.Index(names, "par")
+	if lcd < 0 || par < 0 || lcd > par {
+		t.Errorf("stages %v: lcd must run before par", names)
+	}
+	if result.FinalWidth != 2*result.FinalHeight {
+		t.Errorf("output is %dx%d, want twice as wide as tall", result.FinalWidth, result.FinalHeight)
+	}
+}
@@ -0,0 +1,132 @@
+package converter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// lutSize is the number of entries per channel in a LUT
+const lutSize = 256
+
+// LUT is a per-channel tone curve mapping each 8-bit value to a new one
+type LUT struct {
+	R, G, B [lutSize]uint8
+}
+
+// ApplyLUT1D maps the red, green and blue channels of every pixel through
+// their lookup tables, keeping alpha
+func ApplyLUT1D(img image.Image, rLUT, gLUT, bLUT [256]uint8) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			newImg.SetNRGBA(x, y, color.NRGBA{R: rLUT[c.R], G: gLUT[c.G], B: bLUT[c.B], A: c.A})
+		}
+	}
+
+	return newImg
+}
+
+// LoadLUT reads a LUT from a .json file holding "r", "g" and "b" arrays, or
+// from CSV with one row per input value: either a single column used for all
+// channels or r,g,b columns. Every channel needs exactly 256 entries
+func LoadLUT(path string) (*LUT, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open LUT: %w", err)
+	}
+	defer file.Close()
+
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return decodeLUTJSON(file)
+	}
+	return decodeLUTCSV(file)
+}
+
+func decodeLUTJSON(r io.Reader) (*LUT, error) {
+	var raw struct {
+		R []int `json:"r"`
+		G []int `json:"g"`
+		B []int `json:"b"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid LUT: %w", err)
+	}
+
+	lut := &LUT{}
+	for _, ch := range []struct {
+		name   string
+		values []int
+		dst    *[lutSize]uint8
+	}{{"r", raw.R, &lut.R}, {"g", raw.G, &lut.G}, {"b", raw.B, &lut.B}} {
+		if len(ch.values) != lutSize {
+			return nil, fmt.Errorf("invalid LUT: channel %s has %d entries, want %d", ch.name, len(ch.values), lutSize)
+		}
+		for i, v := range ch.values {
+			if v < 0 || v > 255 {
+				return nil, fmt.Errorf("invalid LUT: channel %s entry %d is %d, want 0-255", ch.name, i, v)
+			}
+			ch.dst[i] = uint8(v)
+		}
+	}
+	return lut, nil
+}
+
+func decodeLUTCSV(r io.Reader) (*LUT, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	lut := &LUT{}
+	rows := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid LUT: %w", err)
+		}
+		if len(record) != 1 && len(record) != 3 {
+			return nil, fmt.Errorf("invalid LUT: row %d has %d columns, want 1 or 3", rows+1, len(record))
+		}
+		if rows == lutSize {
+			return nil, fmt.Errorf("invalid LUT: more than %d rows", lutSize)
+		}
+
+		var values [3]uint8
+		for i, field := range record {
+			v, err := strconv.ParseUint(strings.TrimSpace(field), 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid LUT: row %d: %q is not a value from 0 to 255", rows+1, field)
+			}
+			values[i] = uint8(v)
+		}
+		if len(record) == 1 {
+			values[1], values[2] = values[0], values[0]
+		}
+
+		lut.R[rows], lut.G[rows], lut.B[rows] = values[0], values[1], values[2]
+		rows++
+	}
+
+	if rows != lutSize {
+		return nil, fmt.Errorf("invalid LUT: %d rows, want %d", rows, lutSize)
+	}
+	return lut, nil
+}
@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// medianCutSamples caps how many pixels MedianCut looks at; larger images are
+// sampled on a regular grid
+const medianCutSamples = 1 << 16
+
+// MedianCut builds an adaptive palette of up to numColors colors: the opaque
+// pixels are split repeatedly at the median of the widest channel of the
+// largest box, and each final box contributes its average color
+func MedianCut(img image.Image, numColors int) color.Palette {
+	bounds := img.Bounds()
+	step := 1
+	for (bounds.Dx()/step)*(bounds.Dy()/step) > medianCutSamples {
+		step++
+	}
+
+	var pixels []color.NRGBA
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A > 0 {
+				pixels = append(pixels, c)
+			}
+		}
+	}
+	if len(pixels) == 0 || numColors <= 0 {
+		return nil
+	}
+
+	boxes := [][]color.NRGBA{pixels}
+	for len(boxes) < numColors {
+		// Split the box with the most pixels that still has a spread
+		best, bestChannel := -1, 0
+		for i, box := range boxes {
+			channel, spread := widestChannel(box)
+			if spread > 0 && (best < 0 || len(box) > len(boxes[best])) {
+				best, bestChannel = i, channel
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.Slice(box, func(i, j int) bool {
+			return channelValue(box[i], bestChannel) < channelValue(box[j], bestChannel)
+		})
+		mid := len(box) / 2
+		boxes[best] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		var r, g, b int
+		for _, c := range box {
+			r += int(c.R)
+			g += int(c.G)
+			b += int(c.B)
+		}
+		n := len(box)
+		palette = append(palette, color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: 255})
+	}
+
+	return palette
+}
+
+// widestChannel returns the RGB channel (0-2) with the largest range in box
+// and that range
+func widestChannel(box []color.NRGBA) (int, int) {
+	channel, spread := 0, 0
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := 255, 0
+		for _, c := range box {
+			v := channelValue(c, ch)
+			lo = min(lo, v)
+			hi = max(hi, v)
+		}
+		if hi-lo > spread {
+			channel, spread = ch, hi-lo
+		}
+	}
+	return channel, spread
+}
+
+func channelValue(c color.NRGBA, channel int) int {
+	switch channel {
+	case 0:
+		return int(c.R)
+	case 1:
+		return int(c.G)
+	default:
+		return int(c.B)
+	}
+}
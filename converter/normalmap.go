@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// GenerateNormalMap derives a tangent-space normal map from luminance, for 2D
+// lighting: brighter pixels are treated as higher. Gradients come from a
+// Sobel filter with clamped neighbors at the edges, scaled by strength, and
+// normals are encoded to RGB with green pointing up (OpenGL convention)
+func GenerateNormalMap(img image.Image, strength float64) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	lum := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			lum[y*width+x] = (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 0xffff
+		}
+	}
+	at := func(x, y int) float64 {
+		return lum[clampInt(y, 0, height-1)*width+clampInt(x, 0, width-1)]
+	}
+
+	newImg := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			gx := (at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1)) -
+				(at(x-1, y-1) + 2*at(x-1, y) + at(x-1, y+1))
+			gy := (at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1)) -
+				(at(x-1, y-1) + 2*at(x, y-1) + at(x+1, y-1))
+
+			// Image rows grow downwards while the normal's Y points up
+			nx, ny, nz := -gx*strength, gy*strength, 1.0
+			length := math.Sqrt(nx*nx + ny*ny + nz*nz)
+
+			newImg.SetNRGBA(x, y, color.NRGBA{
+				R: encodeNormal(nx / length),
+				G: encodeNormal(ny / length),
+				B: encodeNormal(nz / length),
+				A: 255,
+			})
+		}
+	}
+
+	return newImg
+}
+
+// encodeNormal maps a normal component from [-1, 1] to [0, 255]
+func encodeNormal(v float64) uint8 {
+	return clampUint8((v*0.5+0.5)*255, 255)
+}
+
+// suffixPath inserts suffix before the extension, e.g. art.png -> art_normal.png
+func suffixPath(path, suffix string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + suffix + ext
+}
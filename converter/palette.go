@@ -0,0 +1,238 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LoadPalette reads a palette from a GIMP .gpl file, a JASC .pal file or a
+// plain list of hex colors, one per line. The format is detected from the
+// "GIMP Palette" or "JASC-PAL" header
+func LoadPalette(path string) (color.Palette, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open palette: %w", err)
+	}
+	defer file.Close()
+
+	var palette color.Palette
+	isGPL, isJASC := false, false
+	lineNum := 0
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if lineNum == 1 && line == "GIMP Palette" {
+			isGPL = true
+			continue
+		}
+		if lineNum == 1 && line == "JASC-PAL" {
+			isJASC = true
+			continue
+		}
+		// The version and color count lines after the JASC header
+		if isJASC && lineNum <= 3 {
+			continue
+		}
+
+		if isGPL || isJASC {
+			c, ok, err := parseGPLLine(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			if ok {
+				palette = append(palette, c)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, ";") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		c, err := ParseHexColor(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		palette = append(palette, c)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read palette: %w", err)
+	}
+
+	if len(palette) == 0 {
+		return nil, fmt.Errorf("palette %s contains no colors", path)
+	}
+
+	return palette, nil
+}
+
+// parseGPLLine parses a "R G B [name]" entry of a GPL or JASC file, reporting ok=false for header
+// and comment lines
+func parseGPLLine(line string) (color.RGBA, bool, error) {
+	if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "Name:") || strings.HasPrefix(line, "Columns:") {
+		return color.RGBA{}, false, nil
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return color.RGBA{}, false, fmt.Errorf("malformed palette entry %q", line)
+	}
+
+	var rgb [3]uint8
+	for i := 0; i < 3; i++ {
+		v, err := strconv.Atoi(fields[i])
+		if err != nil || v < 0 || v > 255 {
+			return color.RGBA{}, false, fmt.Errorf("malformed palette entry %q", line)
+		}
+		rgb[i] = uint8(v)
+	}
+
+	return color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 255}, true, nil
+}
+
+// WritePaletteGPL writes palette as a GIMP palette named name, each color
+// named after its index
+func WritePaletteGPL(w io.Writer, palette color.Palette, name string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "GIMP Palette\nName: %s\nColumns: 0\n#\n", name)
+	for i, c := range palette {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		fmt.Fprintf(bw, "%3d %3d %3d\tIndex %d\n", n.R, n.G, n.B, i)
+	}
+	return bw.Flush()
+}
+
+// WritePaletteJASC writes palette in the JASC-PAL format of Paint Shop Pro,
+// which Aseprite and many other editors also read. The format has no color
+// names, so entries are identified by their line
+func WritePaletteJASC(w io.Writer, palette color.Palette) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "JASC-PAL\r\n0100\r\n%d\r\n", len(palette))
+	for _, c := range palette {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		fmt.Fprintf(bw, "%d %d %d\r\n", n.R, n.G, n.B)
+	}
+	return bw.Flush()
+}
+
+// savePalette writes palette to filename as GPL or JASC-PAL by its extension
+func savePalette(filename string, palette color.Palette, overwrite bool) error {
+	var buf bytes.Buffer
+	var err error
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gpl":
+		name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		err = WritePaletteGPL(&buf, palette, name)
+	case ".pal":
+		err = WritePaletteJASC(&buf, palette)
+	default:
+		return fmt.Errorf("unsupported palette format: %s", filepath.Ext(filename))
+	}
+	if err != nil {
+		return err
+	}
+	return writeOutput(filename, buf.Bytes(), overwrite)
+}
+
+// ParseHexColor parses a #RGB, #RRGGBB or #RRGGBBAA color, the leading '#'
+// being optional. Colors with an alpha byte are returned premultiplied, as
+// color.RGBA expects
+func ParseHexColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	switch len(hex) {
+	case 3:
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	case 6, 8:
+	default:
+		return color.RGBA{}, fmt.Errorf("invalid hex color %q: want #RGB, #RRGGBB or #RRGGBBAA", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid hex color %q: not a hex number", s)
+	}
+	if len(hex) == 6 {
+		v = v<<8 | 0xff
+	}
+
+	c := color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}
+	return color.RGBAModel.Convert(c).(color.RGBA), nil
+}
+
+// QuantizeToPalette maps every pixel to the nearest palette color by RGB
+// distance, keeping the original alpha
+func QuantizeToPalette(img image.Image, palette color.Palette) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			p := color.NRGBAModel.Convert(palette[nearestIndex(palette, c)]).(color.NRGBA)
+			newImg.SetNRGBA(x, y, color.NRGBA{R: p.R, G: p.G, B: p.B, A: c.A})
+		}
+	}
+
+	return newImg
+}
+
+// SnapToPalette maps every pixel to the nearest palette color by RGB
+// distance and makes alpha binary, so the result holds palette colors and
+// transparency only
+func SnapToPalette(img image.Image, palette color.Palette) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			if c.A < 128 {
+				continue
+			}
+			p := color.NRGBAModel.Convert(palette[nearestIndex(palette, c)]).(color.NRGBA)
+			newImg.SetNRGBA(x, y, color.NRGBA{R: p.R, G: p.G, B: p.B, A: 255})
+		}
+	}
+
+	return newImg
+}
+
+// nearestIndex returns the index of the palette entry closest to c in RGB
+// space. Unlike color.Palette.Index it ignores alpha
+func nearestIndex(palette color.Palette, c color.NRGBA) int {
+	best := 0
+	bestDist := -1
+	for i, pc := range palette {
+		p := color.NRGBAModel.Convert(pc).(color.NRGBA)
+		dr := int(c.R) - int(p.R)
+		dg := int(c.G) - int(p.G)
+		db := int(c.B) - int(p.B)
+		dist := dr*dr + dg*dg + db*db
+		if bestDist < 0 || dist < bestDist {
+			best = i
+			bestDist = dist
+		}
+	}
+	return best
+}
@@ -0,0 +1,148 @@
+package converter
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseHexColor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    color.RGBA
+		wantErr bool
+	}{
+		{"#ff8000", color.RGBA{255, 128, 0, 255}, false},
+		{"ff8000", color.RGBA{255, 128, 0, 255}, false},
+		{"  #FF8000 ", color.RGBA{255, 128, 0, 255}, false},
+		{"#f80", color.RGBA{255, 136, 0, 255}, false},
+		{"#ff800080", color.RGBA{128, 64, 0, 128}, false},
+		{"#00000000", color.RGBA{}, false},
+		{"", color.RGBA{}, true},
+		{"#ff80", color.RGBA{}, true},
+		{"#gg8000", color.RGBA{}, true},
+		{"0x1234", color.RGBA{}, true},
+		{"orange", color.RGBA{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseHexColor(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHexColor(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseHexColor(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPaletteHexList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "colors.txt")
+	if err := os.WriteFile(path, []byte("#000\nffffff\n\n#FF0000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	palette, err := LoadPalette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := color.Palette{color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}, color.RGBA{255, 0, 0, 255}}
+	if len(palette) != len(want) {
+		t.Fatalf("got %d colors, want %d", len(palette), len(want))
+	}
+	for i := range want {
+		if color.RGBAModel.Convert(palette[i]) != want[i] {
+			t.Errorf("color %d = %v, want %v", i, palette[i], want[i])
+		}
+	}
+}
+
+func TestSavePaletteRoundTrip(t *testing.T) {
+	palette := color.Palette{
+		color.RGBA{0, 0, 0, 255},
+		color.RGBA{15, 56, 15, 255},
+		color.RGBA{255, 128, 7, 255},
+		color.RGBA{255, 255, 255, 255},
+	}
+	tests := []struct {
+		filename string
+		header   string
+		wantErr  bool
+	}{
+		{"colors.gpl", "GIMP Palette\nName: colors\n", false},
+		{"colors.PAL", "JASC-PAL\r\n0100\r\n4\r\n", false},
+		{"colors.act", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.filename)
+			err := savePalette(path, palette, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("savePalette() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(data), tt.header) {
+				t.Errorf("file starts %q, want %q", data[:min(len(data), 32)], tt.header)
+			}
+
+			loaded, err := LoadPalette(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(loaded) != len(palette) {
+				t.Fatalf("loaded %d colors, want %d", len(loaded), len(palette))
+			}
+			for i := range palette {
+				if color.RGBAModel.Convert(loaded[i]) != palette[i] {
+					t.Errorf("color %d = %v, want %v", i, loaded[i], palette[i])
+				}
+			}
+
+			if err := savePalette(path, palette, false); err == nil {
+				t.Error("savePalette replaced an existing file without overwrite")
+			}
+		})
+	}
+}
+
+func TestPaletteFrom(t *testing.T) {
+	red := color.NRGBA{220, 40, 40, 255}
+	teal := color.NRGBA{20, 160, 150, 255}
+	ref := filepath.Join(t.TempDir(), "ref.png")
+	if err := os.WriteFile(ref, encodeTestPNG(t, splitImage(8, 8, 4, red, teal)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := testConfig()
+	config.PaletteFrom = ref
+	config.Colors = 2
+	if err := config.loadFiles(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Process(gradientImage(64, 64), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Release()
+
+	palette, ok := exactPalette(result.Small, 2)
+	if !ok {
+		t.Fatal("output has more than 2 colors")
+	}
+	for _, c := range palette {
+		if c := color.NRGBAModel.Convert(c); c != red && c != teal {
+			t.Errorf("output color %v isn't from the reference", c)
+		}
+	}
+}
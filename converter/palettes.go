@@ -0,0 +1,97 @@
+package converter
+
+import (
+	"image/color"
+	"sort"
+)
+
+// palettes holds the built-in named palettes selectable with -palette
+var palettes = map[string]color.Palette{
+	"gameboy": hexPalette(
+		"0f380f", "306230", "8bac0f", "9bbc0f",
+	),
+	"cga": hexPalette(
+		"000000", "55ffff", "ff55ff", "ffffff",
+	),
+	"pico8": hexPalette(
+		"000000", "1d2b53", "7e2553", "008751", "ab5236", "5f574f", "c2c3c7", "fff1e8",
+		"ff004d", "ffa300", "ffec27", "00e436", "29adff", "83769c", "ff77a8", "ffccaa",
+	),
+	"nes": hexPalette(
+		"7c7c7c", "0000fc", "0000bc", "4428bc", "940084", "a80020", "a81000", "881400",
+		"503000", "007800", "006800", "005800", "004058", "000000", "bcbcbc", "0078f8",
+		"0058f8", "6844fc", "d800cc", "e40058", "f83800", "e45c10", "ac7c00", "00b800",
+		"00a800", "00a844", "008888", "f8f8f8", "3cbcfc", "6888fc", "9878f8", "f878f8",
+		"f85898", "f87858", "fca044", "f8b800", "b8f818", "58d854", "58f898", "00e8d8",
+		"787878", "fcfcfc", "a4e4fc", "b8b8f8", "d8b8f8", "f8b8f8", "f8a4c0", "f0d0b0",
+		"fce0a8", "f8d878", "d8f878", "b8f8b8", "b8f8d8", "00fcfc", "f8d8f8",
+	),
+	"websafe": webSafePalette(),
+}
+
+// webSafePalette builds the 216-color web-safe palette: every combination of
+// the channel values 0, 51, 102, 153, 204 and 255
+func webSafePalette() color.Palette {
+	palette := make(color.Palette, 0, 216)
+	for r := 0; r <= 255; r += 51 {
+		for g := 0; g <= 255; g += 51 {
+			for b := 0; b <= 255; b += 51 {
+				palette = append(palette, color.RGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: 255})
+			}
+		}
+	}
+	return palette
+}
+
+// NamedPalette returns a copy of the built-in palette with the given name
+func NamedPalette(name string) (color.Palette, bool) {
+	palette, ok := palettes[name]
+	if !ok {
+		return nil, false
+	}
+	return append(color.Palette(nil), palette...), true
+}
+
+// PaletteNames lists the built-in palettes in alphabetical order
+func PaletteNames() []string {
+	names := make([]string, 0, len(palettes))
+	for name := range palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func hexPalette(colors ...string) color.Palette {
+	palette := make(color.Palette, len(colors))
+	for i, hex := range colors {
+		c, err := ParseHexColor(hex)
+		if err != nil {
+			panic(err)
+		}
+		palette[i] = c
+	}
+	return palette
+}
+
+// uniformPalette builds the evenly spaced palette QuantizeColors snaps to, so
+// dithering can target the same colors
+func uniformPalette(numColors int) color.Palette {
+	levels := uniformLevels(numColors)
+	step := 255 / (levels - 1)
+
+	var palette color.Palette
+	for r := 0; r < levels; r++ {
+		for g := 0; g < levels; g++ {
+			for b := 0; b < levels; b++ {
+				palette = append(palette, color.RGBA{
+					R: uint8(min(r*step, 255)),
+					G: uint8(min(g*step, 255)),
+					B: uint8(min(b*step, 255)),
+					A: 255,
+				})
+			}
+		}
+	}
+	return palette
+}
@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestWebSafePalette(t *testing.T) {
+	palette, ok := NamedPalette("websafe")
+	if !ok {
+		t.Fatal("no websafe palette")
+	}
+	if len(palette) != 216 {
+		t.Fatalf("got %d colors, want 216", len(palette))
+	}
+	seen := make(map[color.RGBA]bool)
+	for _, c := range palette {
+		rgba := c.(color.RGBA)
+		for _, v := range []uint8{rgba.R, rgba.G, rgba.B} {
+			if v%51 != 0 {
+				t.Fatalf("%v has channel %d, not a multiple of 51", rgba, v)
+			}
+		}
+		if seen[rgba] {
+			t.Fatalf("%v appears twice", rgba)
+		}
+		seen[rgba] = true
+	}
+}
+
+func TestWebSafeQuantize(t *testing.T) {
+	palette, _ := NamedPalette("websafe")
+	tests := []struct {
+		in   color.NRGBA
+		want color.NRGBA
+	}{
+		{color.NRGBA{0, 0, 0, 255}, color.NRGBA{0, 0, 0, 255}},
+		{color.NRGBA{20, 30, 240, 255}, color.NRGBA{0, 51, 255, 255}},
+		{color.NRGBA{130, 130, 130, 255}, color.NRGBA{153, 153, 153, 255}},
+		{color.NRGBA{250, 100, 10, 128}, color.NRGBA{255, 102, 0, 128}},
+	}
+
+	for _, tt := range tests {
+		img := QuantizeToPalette(solidImage(2, 2, tt.in), palette)
+		if got := color.NRGBAModel.Convert(img.At(0, 0)); got != tt.want {
+			t.Errorf("%v snapped to %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNamedPaletteIsCopy(t *testing.T) {
+	palette, _ := NamedPalette("gameboy")
+	palette[0] = color.RGBA{1, 2, 3, 255}
+	again, _ := NamedPalette("gameboy")
+	if again[0] == palette[0] {
+		t.Error("changing a returned palette changed the built-in one")
+	}
+}
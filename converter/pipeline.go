@@ -0,0 +1,110 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+)
+
+// Downscaler shrinks an image to the pixel grid
+type Downscaler interface {
+	Downscale(img image.Image) image.Image
+}
+
+// Quantizer reduces the colors of the small image
+type Quantizer interface {
+	Quantize(img image.Image) image.Image
+}
+
+// Upscaler enlarges the small image for display
+type Upscaler interface {
+	Upscale(img image.Image) image.Image
+}
+
+// StageFunc adapts a plain function to any of the stage interfaces
+type StageFunc func(img image.Image) image.Image
+
+func (f StageFunc) Downscale(img image.Image) image.Image { return f(img) }
+func (f StageFunc) Quantize(img image.Image) image.Image  { return f(img) }
+func (f StageFunc) Upscale(img image.Image) image.Image   { return f(img) }
+
+// Pipeline holds the core stages of pixel art mode. Set on Config.Stages, any
+// non-nil stage replaces the default built from the rest of the config.
+// Order, when set, reorders all of ProcessContext's stages by name (see
+// StageNames); it must include every enabled stage
+type Pipeline struct {
+	Downscaler Downscaler `json:"-"`
+	Quantizer  Quantizer  `json:"-"`
+	Upscaler   Upscaler   `json:"-"`
+	Order      []string   `json:"order"`
+}
+
+// DefaultPipeline returns the stages ProcessContext uses for config, shrinking
+// to width pixels across
+func DefaultPipeline(config Config, width int) Pipeline {
+	return Pipeline{
+		Downscaler: StageFunc(func(img image.Image) image.Image {
+			blockWidth, blockHeight := config.blockSize()
+			if blockWidth != blockHeight {
+				// Fewer rows of taller pixels, or more of wider ones, so the
+				// upscaled output keeps the input's aspect ratio
+				bounds := img.Bounds()
+				height := proportionalSize(bounds.Dy(), width*blockWidth, bounds.Dx()*blockHeight)
+				return resizeSample(img, width, height, config.Sample)
+			}
+			return DownscaleSample(img, width, config.Sample)
+		}),
+		Quantizer: StageFunc(func(img image.Image) image.Image {
+			return quantize(img, config)
+		}),
+		Upscaler: StageFunc(func(img image.Image) image.Image {
+			if config.Gap > 0 {
+				var gapColor color.Color
+				if config.GapColor != "" {
+					gapColor, _ = ParseHexColor(config.GapColor)
+				}
+				return UpscaleWithGap(img, config.Scale, config.Gap, gapColor)
+			}
+			blockWidth, blockHeight := config.blockSize()
+			return UpscaleBlocks(img, blockWidth, blockHeight)
+		}),
+	}
+}
+
+// Run applies the stages in order, skipping nil ones. Intermediate images
+// go back to the buffer pool once the next stage is done with them, so
+// stages must return a new image or their input, without keeping the input.
+// Run is safe for concurrent use when the stages are
+func (p Pipeline) Run(img image.Image) image.Image {
+	input := img
+	step := func(next image.Image) {
+		if !sharesPixels(img, input) && !sharesPixels(img, next) {
+			release(img)
+		}
+		img = next
+	}
+
+	if p.Downscaler != nil {
+		step(p.Downscaler.Downscale(img))
+	}
+	if p.Quantizer != nil {
+		step(p.Quantizer.Quantize(img))
+	}
+	if p.Upscaler != nil {
+		step(p.Upscaler.Upscale(img))
+	}
+	return img
+}
+
+// merge returns p with its nil stages filled from defaults
+func (p Pipeline) merge(defaults Pipeline) Pipeline {
+	if p.Downscaler == nil {
+		p.Downscaler = defaults.Downscaler
+	}
+	if p.Quantizer == nil {
+		p.Quantizer = defaults.Quantizer
+	}
+	if p.Upscaler == nil {
+		p.Upscaler = defaults.Upscaler
+	}
+	return p
+}
@@ -0,0 +1,84 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+)
+
+// MetadataKey is the tEXt keyword under which PNG output records the
+// settings it was converted with
+const MetadataKey = "pixgrid"
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// metadata is the subset of the config needed to reproduce an output
+type metadata struct {
+	Size    int    `json:"size,omitempty"`
+	Percent int    `json:"percent,omitempty"`
+	Scale   int    `json:"scale"`
+	Colors  int    `json:"colors"`
+	Mode    string `json:"mode,omitempty"`
+	Sample  string `json:"sample,omitempty"`
+	Palette string `json:"palette,omitempty"`
+	Dither  string `json:"dither,omitempty"`
+}
+
+// metadata returns the conversion settings as JSON for embedding in output
+func (c Config) metadata() string {
+	palette := c.PaletteName
+	if c.PaletteFile != "" {
+		palette = c.PaletteFile
+	}
+	if c.PaletteFrom != "" {
+		palette = c.PaletteFrom
+	}
+
+	data, _ := json.Marshal(metadata{
+		Size:    c.PixelSize,
+		Percent: c.Percent,
+		Scale:   c.Scale,
+		Colors:  c.Colors,
+		Mode:    c.Mode,
+		Sample:  c.Sample,
+		Palette: palette,
+		Dither:  c.Dither,
+	})
+	return string(data)
+}
+
+// addPNGText inserts a tEXt chunk holding keyword and text just before the
+// IEND chunk of an encoded PNG. The image data is left untouched
+func addPNGText(data []byte, keyword, text string) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("not a PNG")
+	}
+	if len(keyword) == 0 || len(keyword) > 79 {
+		return nil, fmt.Errorf("tEXt keyword must be 1 to 79 bytes, got %d", len(keyword))
+	}
+
+	// Walk the chunks to find IEND rather than trusting the last 12 bytes
+	offset := len(pngSignature)
+	for offset+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		if string(data[offset+4:offset+8]) == "IEND" {
+			payload := append([]byte(keyword+"\x00"), text...)
+
+			chunk := make([]byte, 0, len(payload)+12)
+			chunk = binary.BigEndian.AppendUint32(chunk, uint32(len(payload)))
+			chunk = append(chunk, "tEXt"...)
+			chunk = append(chunk, payload...)
+			chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+			out := make([]byte, 0, len(data)+len(chunk))
+			out = append(out, data[:offset]...)
+			out = append(out, chunk...)
+			return append(out, data[offset:]...), nil
+		}
+		offset += length + 12
+	}
+
+	return nil, fmt.Errorf("PNG has no IEND chunk")
+}
@@ -0,0 +1,103 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngText returns the tEXt chunks of an encoded PNG by keyword, failing on a
+// bad chunk CRC
+func pngText(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	texts := make(map[string]string)
+	for offset := len(pngSignature); offset+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		chunk := data[offset+4 : offset+8+length]
+		if crc := binary.BigEndian.Uint32(data[offset+8+length:]); crc != crc32.ChecksumIEEE(chunk) {
+			t.Fatalf("%s chunk has a bad CRC", chunk[:4])
+		}
+		if string(chunk[:4]) == "tEXt" {
+			keyword, text, _ := strings.Cut(string(chunk[4:]), "\x00")
+			texts[keyword] = text
+		}
+		offset += length + 12
+	}
+	return texts
+}
+
+func TestAddPNGText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gradientImage(8, 8)); err != nil {
+		t.Fatal(err)
+	}
+	valid := buf.Bytes()
+
+	tests := []struct {
+		name    string
+		data    []byte
+		keyword string
+		wantErr bool
+	}{
+		{"png", valid, MetadataKey, false},
+		{"empty keyword", valid, "", true},
+		{"long keyword", valid, strings.Repeat("k", 80), true},
+		{"not a png", []byte("GIF89a"), MetadataKey, true},
+		{"truncated", valid[:len(valid)-12], MetadataKey, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := addPNGText(tt.data, tt.keyword, `{"size":8}`)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("addPNGText() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := pngText(t, out)[tt.keyword]; got != `{"size":8}` {
+				t.Errorf("tEXt = %q", got)
+			}
+			if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+				t.Errorf("output no longer decodes: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfigMetadata(t *testing.T) {
+	config := testConfig()
+	config.Colors = 8
+	config.PaletteName = "gameboy"
+	config.Dither = DitherOrdered
+
+	var got metadata
+	if err := json.Unmarshal([]byte(config.metadata()), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := metadata{Size: 16, Scale: 2, Colors: 8, Mode: config.Mode, Sample: config.Sample, Palette: "gameboy", Dither: DitherOrdered}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSaveOutputMetadata(t *testing.T) {
+	config := testConfig()
+	path := filepath.Join(t.TempDir(), "out.png")
+	if err := saveOutput(path, gradientImage(8, 8), config); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pngText(t, data)[MetadataKey]; got != config.metadata() {
+		t.Errorf("tEXt = %q, want %q", got, config.metadata())
+	}
+}
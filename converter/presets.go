@@ -0,0 +1,105 @@
+package converter
+
+import "sort"
+
+// presets are named bundles of settings applied on top of DefaultConfig
+var presets = map[string]func(*Config){
+	// Game Boy: 160px wide screen, four shades of green
+	"gameboy": func(c *Config) {
+		c.PaletteName = "gameboy"
+		c.Colors = 4
+		c.Dither = DitherOrdered
+		c.PixelSize = 160
+		c.Scale = 4
+	},
+	// CGA mode 4: 320px wide, cyan/magenta/white palette
+	"cga": func(c *Config) {
+		c.PaletteName = "cga"
+		c.Colors = 4
+		c.Dither = DitherOrdered
+		c.PixelSize = 320
+		c.Scale = 2
+	},
+	// NES: 256px wide, the console's fixed master palette
+	"nes": func(c *Config) {
+		c.PaletteName = "nes"
+		c.Colors = 55
+		c.Dither = DitherOrdered
+		c.PixelSize = 256
+		c.Scale = 3
+	},
+}
+
+// sourceTypes are bundles of sampling, dithering and color defaults suited
+// to a kind of source image. They only touch those settings, so a preset
+// or explicit flags can still override them
+var sourceTypes = map[string]func(*Config){
+	// Icons: flat shapes with dark outlines, which edge sampling keeps
+	"icon": func(c *Config) {
+		c.Sample = SampleEdge
+		c.Dither = DitherNone
+		c.Colors = 16
+	},
+	// Photos: smooth gradients, averaged and then diffused so they don't band
+	"photo": func(c *Config) {
+		c.Sample = SampleAverage
+		c.Dither = DitherFloydSteinberg
+		c.Colors = 32
+	},
+	// Line art: thin single-color strokes that averaging would blur away and
+	// dithering would fray
+	"lineart": func(c *Config) {
+		c.Sample = SampleMode
+		c.Dither = DitherNone
+		c.Colors = 8
+	},
+}
+
+// Preset returns DefaultConfig with the named preset applied
+func Preset(name string) (Config, error) {
+	config := DefaultConfig()
+	if err := ApplyPreset(&config, name); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// ApplyPreset sets the named preset's settings on config, leaving the rest
+func ApplyPreset(config *Config, name string) error {
+	apply, ok := presets[name]
+	if !ok {
+		return CheckEnum("preset", name, PresetNames())
+	}
+	apply(config)
+	return nil
+}
+
+// ApplySourceType sets the sampling, dithering and color defaults of the
+// named source type (icon, photo or lineart) on config
+func ApplySourceType(config *Config, name string) error {
+	apply, ok := sourceTypes[name]
+	if !ok {
+		return CheckEnum("source type", name, SourceTypeNames())
+	}
+	apply(config)
+	return nil
+}
+
+// SourceTypeNames lists the available source types in alphabetical order
+func SourceTypeNames() []string {
+	return sortedKeys(sourceTypes)
+}
+
+// PresetNames lists the available presets in alphabetical order
+func PresetNames() []string {
+	return sortedKeys(presets)
+}
+
+func sortedKeys(m map[string]func(*Config)) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
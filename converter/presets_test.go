@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestPreset(t *testing.T) {
+	for _, name := range PresetNames() {
+		t.Run(name, func(t *testing.T) {
+			config, err := Preset(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := config.Validate(); err != nil {
+				t.Errorf("preset config is invalid: %v", err)
+			}
+			if config.PaletteName != name {
+				t.Errorf("palette = %q, want %q", config.PaletteName, name)
+			}
+		})
+	}
+
+	if _, err := Preset("amiga"); err == nil {
+		t.Error("Preset accepted an unknown name")
+	}
+}
+
+func TestApplySourceType(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		dither string
+		colors int
+	}{
+		{"icon", SampleEdge, DitherNone, 16},
+		{"photo", SampleAverage, DitherFloydSteinberg, 32},
+		{"lineart", SampleMode, DitherNone, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Scale = 5
+			if err := ApplySourceType(&config, tt.name); err != nil {
+				t.Fatal(err)
+			}
+			if config.Sample != tt.sample || config.Dither != tt.dither || config.Colors != tt.colors {
+				t.Errorf("got sample %q, dither %q, %d colors; want %q, %q, %d",
+					config.Sample, config.Dither, config.Colors, tt.sample, tt.dither, tt.colors)
+			}
+			if config.Scale != 5 {
+				t.Error("source type changed an unrelated setting")
+			}
+			if err := config.Validate(); err != nil {
+				t.Errorf("source type config is invalid: %v", err)
+			}
+		})
+	}
+	if len(tests) != len(SourceTypeNames()) {
+		t.Errorf("tested %d source types, there are %d", len(tests), len(SourceTypeNames()))
+	}
+
+	config := DefaultConfig()
+	if err := ApplySourceType(&config, "painting"); err == nil {
+		t.Error("ApplySourceType accepted an unknown name")
+	}
+}
+
+func TestPresetOutputUsesPalette(t *testing.T) {
+	for _, name := range PresetNames() {
+		t.Run(name, func(t *testing.T) {
+			config, err := Preset(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config.Logger = testConfig().Logger
+			config.PixelSize = 16
+			config.Scale = 1
+
+			result, err := Process(gradientImage(64, 64), config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer result.Release()
+
+			palette, _ := NamedPalette(name)
+			allowed := make(map[color.NRGBA]bool)
+			for _, c := range palette {
+				allowed[color.NRGBAModel.Convert(c).(color.NRGBA)] = true
+			}
+			b := result.Image.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if c := color.NRGBAModel.Convert(result.Image.At(x, y)).(color.NRGBA); !allowed[c] {
+						t.Fatalf("pixel (%d,%d) = %v is not in the %s palette", x, y, c, name)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestProcessContextCancelsSlowStage(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	config := testConfig()
+	config.Stages.Downscaler = StageFunc(func(img image.Image) image.Image {
+		<-release
+		return img
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := ProcessContext(ctx, gradientImage(32, 32), config)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v, want soon after the deadline", elapsed)
+	}
+}
+
+func TestProcessContextDoneWaitsForStage(t *testing.T) {
+	release := make(chan struct{})
+	config := testConfig()
+	config.Stages.Downscaler = StageFunc(func(img image.Image) image.Image {
+		<-release
+		return img
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, done, err := ProcessContextDone(ctx, gradientImage(32, 32), config)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	select {
+	case <-done:
+		t.Fatal("done closed while the stage was still running")
+	default:
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("done not closed after the stage finished")
+	}
+
+	result, done, err := ProcessContextDone(context.Background(), gradientImage(32, 32), testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.Release()
+	select {
+	case <-done:
+	default:
+		t.Error("done not closed after a finished conversion")
+	}
+}
+
+func TestProcessContextStagePanic(t *testing.T) {
+	config := testConfig()
+	config.Stages.Quantizer = StageFunc(func(img image.Image) image.Image {
+		panic("broken stage")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("a panicking stage didn't panic in the caller")
+		}
+	}()
+	ProcessContext(ctx, gradientImage(32, 32), config)
+}
+
+func TestProcessScale(t *testing.T) {
+	tests := []struct {
+		name  string
+		scale int
+	}{
+		{"disabled", 1},
+		{"double", 2},
+		{"odd", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Scale = tt.scale
+			result, err := Process(gradientImage(64, 48), config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer result.Release()
+
+			small := result.Small.Bounds()
+			if result.FinalWidth != small.Dx()*tt.scale || result.FinalHeight != small.Dy()*tt.scale {
+				t.Errorf("final %dx%d, want the %dx%d grid times %d",
+					result.FinalWidth, result.FinalHeight, small.Dx(), small.Dy(), tt.scale)
+			}
+			got := color.NRGBAModel.Convert(result.Image.At(result.FinalWidth-1, 0))
+			want := color.NRGBAModel.Convert(result.Small.At(small.Max.X-1, small.Min.Y))
+			if got != want {
+				t.Errorf("top-right pixel = %v, want %v from the grid", got, want)
+			}
+		})
+	}
+}
@@ -10,12 +10,9 @@ func QuantizeColors(img image.Image, numColors int) image.Image {
 	width := bounds.Dx()
 	height := bounds.Dy()
 
-	newImg := image.NewRGBA(image.Rect(0, 0, width, height))
+	newImg := newRGBA(width, height)
 
-	levelsPerChannel := int(float64(numColors) / 3.0)
-	if levelsPerChannel < 2 {
-		levelsPerChannel = 2
-	}
+	levelsPerChannel := uniformLevels(numColors)
 
 	step := 255 / (levelsPerChannel - 1)
 
@@ -41,6 +38,16 @@ func QuantizeColors(img image.Image, numColors int) image.Image {
 	return newImg
 }
 
+// uniformLevels is the number of levels per channel QuantizeColors uses for
+// the requested color count
+func uniformLevels(numColors int) int {
+	levels := int(float64(numColors) / 3.0)
+	if levels < 2 {
+		levels = 2
+	}
+	return levels
+}
+
 func quantizeChannel(value uint8, step int) uint8 {
 	level := int(float64(value)/float64(step) + 0.5)
 	result := level * step
@@ -50,4 +57,4 @@ func quantizeChannel(value uint8, step int) uint8 {
 	}
 
 	return uint8(result)
-}
\ No newline at end of file
+}
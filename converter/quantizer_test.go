@@ -0,0 +1,108 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestExactPalette(t *testing.T) {
+	three := splitImage(8, 8, 3, color.NRGBA{13, 77, 201, 255}, color.NRGBA{250, 1, 99, 255})
+	three.Set(7, 7, color.NRGBA{5, 5, 5, 255})
+	tests := []struct {
+		name     string
+		img      image.Image
+		limit    int
+		wantLen  int
+		wantFits bool
+	}{
+		{"solid", solidImage(4, 4, color.White), 1, 1, true},
+		{"under limit", three, 8, 3, true},
+		{"at limit", three, 3, 3, true},
+		{"over limit", three, 2, 0, false},
+		{"gradient", gradientImage(32, 32), 256, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			palette, fits := exactPalette(tt.img, tt.limit)
+			if fits != tt.wantFits || len(palette) != tt.wantLen {
+				t.Errorf("got %d colors, fits %v; want %d, %v", len(palette), fits, tt.wantLen, tt.wantFits)
+			}
+		})
+	}
+}
+
+func TestQuantizeSkipsFewColors(t *testing.T) {
+	odd := color.NRGBA{13, 77, 201, 255}
+	src := splitImage(8, 8, 3, odd, color.NRGBA{250, 1, 99, 255})
+	tests := []struct {
+		name   string
+		colors int
+		kept   bool
+	}{
+		{"enough colors", 8, true},
+		{"exact count", 2, true},
+		{"too few colors", 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Colors = tt.colors
+			got := quantizeColors(src, config)
+			if kept := got == image.Image(src); kept != tt.kept {
+				t.Errorf("image kept = %v, want %v", kept, tt.kept)
+			}
+			// Uniform levels would have moved the odd color
+			if c := color.NRGBAModel.Convert(got.At(0, 0)); tt.kept && c != odd {
+				t.Errorf("color = %v, want %v", c, odd)
+			}
+		})
+	}
+}
+
+func TestProcessFlatLogo(t *testing.T) {
+	// Four flat quadrants whose borders fall inside sampling blocks, so
+	// averaging blends them into extra shades
+	logo := []color.NRGBA{{230, 30, 40, 255}, {20, 60, 200, 255}, {250, 210, 20, 255}, {30, 30, 30, 255}}
+	src := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			i := 0
+			if x >= 30 {
+				i++
+			}
+			if y >= 30 {
+				i += 2
+			}
+			src.SetNRGBA(x, y, logo[i])
+		}
+	}
+
+	config := testConfig()
+	config.Sample = SampleAverage
+	config.Colors = 4
+	config.Flat = true
+	result, err := Process(src, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Release()
+
+	if palette, ok := exactPalette(result.Small, 4); !ok || len(palette) != 4 {
+		t.Fatalf("got %d colors (fits %v), want exactly 4", len(palette), ok)
+	}
+	// Away from the blended borders every quadrant is one solid color
+	quadrants := []image.Rectangle{image.Rect(0, 0, 7, 7), image.Rect(8, 0, 16, 7), image.Rect(0, 8, 7, 16), image.Rect(8, 8, 16, 16)}
+	for _, quadrant := range quadrants {
+		want := result.Small.At(quadrant.Min.X, quadrant.Min.Y)
+		for y := quadrant.Min.Y; y < quadrant.Max.Y; y++ {
+			for x := quadrant.Min.X; x < quadrant.Max.X; x++ {
+				if result.Small.At(x, y) != want {
+					t.Fatalf("speckle at (%d, %d) in quadrant %v", x, y, quadrant)
+				}
+			}
+		}
+	}
+}
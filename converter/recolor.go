@@ -0,0 +1,52 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+)
+
+// RemapPalette swaps colors by index: every pixel whose RGB equals from[i]
+// becomes to[i], keeping its alpha. Other pixels pass through unchanged
+func RemapPalette(img image.Image, from, to color.Palette) image.Image {
+	return remapPalette(img, from, to, false)
+}
+
+// RemapPaletteNearest is like RemapPalette but snaps pixels that match no
+// from entry to the nearest one first, so every pixel is recolored
+func RemapPaletteNearest(img image.Image, from, to color.Palette) image.Image {
+	return remapPalette(img, from, to, true)
+}
+
+func remapPalette(img image.Image, from, to color.Palette, snap bool) image.Image {
+	n := min(len(from), len(to))
+	from, to = from[:n], to[:n]
+
+	index := make(map[[3]uint8]int, n)
+	for i := len(from) - 1; i >= 0; i-- {
+		c := color.NRGBAModel.Convert(from[i]).(color.NRGBA)
+		index[[3]uint8{c.R, c.G, c.B}] = i
+	}
+
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	newImg := newNRGBA(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			i, ok := index[[3]uint8{c.R, c.G, c.B}]
+			if !ok && snap && n > 0 {
+				i, ok = nearestIndex(from, c), true
+			}
+			if ok {
+				p := color.NRGBAModel.Convert(to[i]).(color.NRGBA)
+				c.R, c.G, c.B = p.R, p.G, p.B
+			}
+			newImg.SetNRGBA(x, y, c)
+		}
+	}
+
+	return newImg
+}
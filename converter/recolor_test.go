@@ -0,0 +1,41 @@
+package converter
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestRemapPaletteSwap(t *testing.T) {
+	dark := color.NRGBA{20, 30, 60, 255}
+	light := color.NRGBA{240, 220, 150, 255}
+	other := color.NRGBA{100, 100, 100, 255}
+	swap := color.Palette{light, dark}
+
+	src := splitImage(6, 2, 2, dark, light)
+	src.SetNRGBA(5, 1, other)
+	tests := []struct {
+		name  string
+		snap  bool
+		other color.NRGBA
+	}{
+		{"unmatched passes through", false, other},
+		// other is nearest to dark, which becomes light
+		{"unmatched snaps", true, light},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remap := RemapPalette
+			if tt.snap {
+				remap = RemapPaletteNearest
+			}
+			got := remap(src, color.Palette{dark, light}, swap)
+			wants := map[[2]int]color.NRGBA{{0, 0}: light, {1, 1}: light, {2, 0}: dark, {4, 1}: dark, {5, 1}: tt.other}
+			for p, want := range wants {
+				if c := color.NRGBAModel.Convert(got.At(p[0], p[1])); c != want {
+					t.Errorf("pixel %v = %v, want %v", p, c, want)
+				}
+			}
+		})
+	}
+}
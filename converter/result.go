@@ -0,0 +1,114 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+)
+
+// maxResultPalette is the most distinct colors Result.Palette collects from
+// an image quantized by color count
+const maxResultPalette = 256
+
+// Result is the outcome of a conversion: the final image along with what
+// UIs and tooling need to describe it
+type Result struct {
+	// Image is the final output
+	Image image.Image
+	// Small is the image as it entered the upscale stage: the pixel grid in
+	// pixel art mode, before enlarging and the effects that follow
+	Small image.Image
+	// Palette holds the colors quantized to: the configured palette, or the
+	// distinct colors of Small. It is nil when those exceed 256
+	Palette color.Palette
+
+	OriginalWidth  int
+	OriginalHeight int
+	FinalWidth     int
+	FinalHeight    int
+
+	// Duration is the time the whole pipeline took and Stages the time of
+	// each stage that ran, in order
+	Duration time.Duration
+	Stages   []StageTiming
+
+	// input is the image the conversion started from, which Release leaves
+	// to the caller
+	input image.Image
+}
+
+// Release hands the buffers of Image and Small back for reuse by later
+// conversions, easing GC pressure under load. It is optional, and neither
+// image may be used afterwards, so call it once they have been encoded
+func (r *Result) Release() {
+	if r.Image != nil && !sharesPixels(r.Image, r.input) {
+		release(r.Image)
+	}
+	if r.Small != nil && !sharesPixels(r.Small, r.input) && !sharesPixels(r.Small, r.Image) {
+		release(r.Small)
+	}
+	r.Image, r.Small = nil, nil
+}
+
+// StageTiming is how long one pipeline stage took
+type StageTiming struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+// visibleColors counts the distinct colors of img, fully transparent pixels
+// not counting as one
+func visibleColors(img image.Image) int {
+	count := CountColors(img)
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a == 0 {
+				return count - 1
+			}
+		}
+	}
+	return count
+}
+
+// strictColors checks that img has no more colors than config asks for,
+// which effects after quantizing and uniform quantizing itself can break,
+// and snaps it to a palette within the limit if not: palette when it fits,
+// else a median-cut palette of small. It returns the palette img ends up in
+func strictColors(img, small image.Image, palette color.Palette, config Config) (image.Image, color.Palette, error) {
+	limit := len(palette)
+	counted := len(config.Palette) == 0 && config.PaletteName == "" && !config.BlackWhite && config.Colors > 0
+	if counted {
+		limit = config.Colors
+	}
+
+	count := visibleColors(img)
+	if count <= limit {
+		return img, palette, nil
+	}
+	if palette == nil || len(palette) > limit {
+		if !counted {
+			return nil, nil, fmt.Errorf("result has %d colors, more than the %d allowed", count, limit)
+		}
+		palette = MedianCut(small, limit)
+	}
+	config.logger().Info("Snapped to palette", "colors", count, "limit", limit)
+	return SnapToPalette(img, palette), palette, nil
+}
+
+// resultPalette returns the palette the config quantizes to, falling back to
+// the distinct colors of small
+func resultPalette(small image.Image, config Config) color.Palette {
+	if len(config.Palette) > 0 {
+		return config.Palette
+	}
+	if palette, ok := NamedPalette(config.PaletteName); ok {
+		return palette
+	}
+	palette, ok := exactPalette(small, maxResultPalette)
+	if !ok {
+		return nil
+	}
+	return palette
+}
@@ -0,0 +1,172 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Sample modes choose how Downscale picks the color for each output pixel
+const (
+	// SampleCenter takes the source pixel at the center of the block
+	SampleCenter = "center"
+	// SampleAverage averages every pixel in the block
+	SampleAverage = "average"
+	// SampleMode takes the most frequent color in the block, which keeps
+	// thin single-color lines that averaging would blur away
+	SampleMode = "mode"
+	// SampleLanczos resamples with a Lanczos-3 filter, best for photos
+	SampleLanczos = "lanczos"
+	// SampleEdge takes the darkest pixel of blocks crossed by an edge and
+	// averages flat ones, keeping thin dark outlines of line art
+	SampleEdge = "edge"
+)
+
+// edgeVariance is the luminance variance above which SampleEdge treats a
+// block as crossed by an edge, a standard deviation of about 20 levels
+const edgeVariance = 400
+
+// DownscaleSample is Downscale with a choice of sample mode. Each output pixel
+// covers a block of source pixels; SampleCenter gives the same result as
+// Downscale
+func DownscaleSample(img image.Image, targetWidth int, sample string) image.Image {
+	bounds := img.Bounds()
+	return resizeSample(img, targetWidth, proportionalSize(bounds.Dy(), targetWidth, bounds.Dx()), sample)
+}
+
+// resizeSample is DownscaleSample to an exact size, which needn't keep the
+// aspect ratio
+func resizeSample(img image.Image, targetWidth, targetHeight int, sample string) image.Image {
+	var pick func(img image.Image, block image.Rectangle) color.Color
+	switch sample {
+	case SampleAverage:
+		pick = blockAverage
+	case SampleMode:
+		pick = newBlockMode()
+	case SampleEdge:
+		pick = blockEdge
+	case SampleLanczos:
+		return resizeLanczos(img, targetWidth, targetHeight)
+	default:
+		return resizeNearest(img, targetWidth, targetHeight)
+	}
+
+	return sampleBlocks(img, targetWidth, targetHeight, pick)
+}
+
+// sampleBlocks resizes img to width x height, coloring each output pixel with
+// pick applied to the source block it covers. Enlarging gives one-pixel
+// blocks, i.e. nearest neighbor
+func sampleBlocks(img image.Image, width, height int, pick func(img image.Image, block image.Rectangle) color.Color) image.Image {
+	bounds := img.Bounds()
+	origWidth := bounds.Dx()
+	origHeight := bounds.Dy()
+	targetWidth, targetHeight := width, height
+
+	newImg := newRGBA(targetWidth, targetHeight)
+
+	for y := 0; y < targetHeight; y++ {
+		y0 := y * origHeight / targetHeight
+		y1 := max((y+1)*origHeight/targetHeight, y0+1)
+		for x := 0; x < targetWidth; x++ {
+			x0 := x * origWidth / targetWidth
+			x1 := max((x+1)*origWidth/targetWidth, x0+1)
+
+			block := image.Rect(x0, y0, x1, y1).Add(bounds.Min).Intersect(bounds)
+			newImg.Set(x, y, pick(img, block))
+		}
+	}
+
+	return newImg
+}
+
+// blockAverage returns the mean color of the pixels in block
+func blockAverage(img image.Image, block image.Rectangle) color.Color {
+	var sr, sg, sb, sa, n uint32
+	for y := block.Min.Y; y < block.Max.Y; y++ {
+		for x := block.Min.X; x < block.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			sr += r >> 8
+			sg += g >> 8
+			sb += b >> 8
+			sa += a >> 8
+			n++
+		}
+	}
+	if n == 0 {
+		return color.RGBA{}
+	}
+	return color.RGBA{R: uint8(sr / n), G: uint8(sg / n), B: uint8(sb / n), A: uint8(sa / n)}
+}
+
+// newBlockMode returns a picker for the most frequent color in a block,
+// reusing one count map across blocks. On a tie the color that reached the
+// top count first, scanning rows top to bottom, wins
+func newBlockMode() func(img image.Image, block image.Rectangle) color.Color {
+	counts := make(map[color.RGBA]int)
+	return func(img image.Image, block image.Rectangle) color.Color {
+		clear(counts)
+
+		var best color.RGBA
+		bestCount := 0
+		for y := block.Min.Y; y < block.Max.Y; y++ {
+			for x := block.Min.X; x < block.Max.X; x++ {
+				c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+				counts[c]++
+				if counts[c] > bestCount {
+					best, bestCount = c, counts[c]
+				}
+			}
+		}
+		return best
+	}
+}
+
+// blockEdge returns the darkest opaque pixel of a block whose luminance
+// varies by more than edgeVariance, and the block average otherwise
+func blockEdge(img image.Image, block image.Rectangle) color.Color {
+	var sum, sumSq float64
+	var darkest color.Color
+	darkestLum := math.MaxFloat64
+	n := 0
+	for y := block.Min.Y; y < block.Max.Y; y++ {
+		for x := block.Min.X; x < block.Max.X; x++ {
+			c := img.At(x, y)
+			r, g, b, a := c.RGBA()
+			if a == 0 {
+				continue
+			}
+			lum := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+			sum += lum
+			sumSq += lum * lum
+			n++
+			if lum < darkestLum {
+				darkest, darkestLum = c, lum
+			}
+		}
+	}
+
+	if n > 0 {
+		mean := sum / float64(n)
+		if sumSq/float64(n)-mean*mean > edgeVariance {
+			return darkest
+		}
+	}
+	return blockAverage(img, block)
+}
+
+// Thumbnail shrinks img to fit within maxSize x maxSize by box averaging,
+// which looks smoother than the pixel grid sampling for previews. Images that
+// already fit are returned as is
+func Thumbnail(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return img
+	}
+
+	if width >= height {
+		return sampleBlocks(img, maxSize, proportionalSize(height, maxSize, width), blockAverage)
+	}
+	return sampleBlocks(img, proportionalSize(width, maxSize, height), maxSize, blockAverage)
+}
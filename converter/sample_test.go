@@ -0,0 +1,94 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// thinLineImage is an 8x8 blue image with one red column per 4x4 block, so
+// red is a quarter of every block
+func thinLineImage() *image.NRGBA {
+	img := solidImage(8, 8, color.NRGBA{0, 0, 255, 255})
+	for y := 0; y < 8; y++ {
+		img.Set(1, y, color.NRGBA{255, 0, 0, 255})
+		img.Set(5, y, color.NRGBA{255, 0, 0, 255})
+	}
+	return img
+}
+
+func TestDownscaleSampleMode(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	blue := color.NRGBA{0, 0, 255, 255}
+	tests := []struct {
+		name   string
+		img    image.Image
+		sample string
+		want   color.NRGBA
+	}{
+		{"majority", thinLineImage(), SampleMode, blue},
+		{"average", thinLineImage(), SampleAverage, color.NRGBA{64, 0, 191, 255}},
+		{"majority red", splitImage(8, 8, 7, red, blue), SampleMode, red},
+		{"solid", solidImage(8, 8, red), SampleMode, red},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DownscaleSample(tt.img, 2, tt.sample)
+			if size := got.Bounds().Size(); size != image.Pt(2, 2) {
+				t.Fatalf("size = %v, want 2x2", size)
+			}
+			if c := color.NRGBAModel.Convert(got.At(0, 0)).(color.NRGBA); !nearColor(c, tt.want, 1) {
+				t.Errorf("got %v, want %v", c, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownscaleSampleModeTie(t *testing.T) {
+	// Two colors with equal counts must pick the same one every time
+	img := splitImage(8, 8, 4, color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255})
+	first := color.NRGBAModel.Convert(DownscaleSample(img, 1, SampleMode).At(0, 0))
+	for i := 0; i < 20; i++ {
+		if c := color.NRGBAModel.Convert(DownscaleSample(img, 1, SampleMode).At(0, 0)); c != first {
+			t.Fatalf("run %d picked %v, first run picked %v", i, c, first)
+		}
+	}
+}
+
+func TestDownscaleSampleEdge(t *testing.T) {
+	// A light image crossed by one dark column in the first 4x4 block
+	ink := color.NRGBA{20, 20, 20, 255}
+	paper := color.NRGBA{230, 230, 210, 255}
+	img := solidImage(8, 8, paper)
+	for y := 0; y < 8; y++ {
+		img.SetNRGBA(2, y, ink)
+	}
+
+	tests := []struct {
+		sample string
+		want   color.NRGBA
+	}{
+		{SampleEdge, ink},
+		// Averaging washes the line out to a quarter ink
+		{SampleAverage, color.NRGBA{177, 177, 162, 255}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sample, func(t *testing.T) {
+			got := DownscaleSample(img, 2, tt.sample)
+			if c := color.NRGBAModel.Convert(got.At(0, 0)).(color.NRGBA); !nearColor(c, tt.want, 1) {
+				t.Errorf("block with the line = %v, want %v", c, tt.want)
+			}
+			// The flat block beside it is just averaged
+			if c := color.NRGBAModel.Convert(got.At(1, 0)).(color.NRGBA); c != paper {
+				t.Errorf("flat block = %v, want %v", c, paper)
+			}
+		})
+	}
+}
+
+// nearColor reports whether every channel of a and b is within tolerance
+func nearColor(a, b color.NRGBA, tolerance int) bool {
+	return channelDiff(a.R, b.R) <= tolerance && channelDiff(a.G, b.G) <= tolerance &&
+		channelDiff(a.B, b.B) <= tolerance && channelDiff(a.A, b.A) <= tolerance
+}
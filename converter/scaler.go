@@ -1,25 +1,36 @@
 package converter
 
-import "image"
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
 
 func Downscale(img image.Image, targetWidth int) image.Image {
+	bounds := img.Bounds()
+	return resizeNearest(img, targetWidth, proportionalSize(bounds.Dy(), targetWidth, bounds.Dx()))
+}
+
+// resizeNearest resizes img to targetWidth x targetHeight, taking the source
+// pixel nearest to the center of each output pixel
+func resizeNearest(img image.Image, targetWidth, targetHeight int) image.Image {
 	bounds := img.Bounds()
 	origWidth := bounds.Dx()
 	origHeight := bounds.Dy()
 
-	targetHeight := (origHeight * targetWidth) / origWidth
-
-	newImg := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	newImg := newRGBA(targetWidth, targetHeight)
 
 	scaleX := float64(origWidth) / float64(targetWidth)
 	scaleY := float64(origHeight) / float64(targetHeight)
 
 	for y := 0; y < targetHeight; y++ {
 		for x := 0; x < targetWidth; x++ {
-			srcX := int((float64(x) + 0.5) * scaleX)
-			srcY := int((float64(y) + 0.5) * scaleY)
+			// Rounding can land one past the last row or column when the
+			// target is close to the original size
+			srcX := clampInt(int((float64(x)+0.5)*scaleX), 0, origWidth-1)
+			srcY := clampInt(int((float64(y)+0.5)*scaleY), 0, origHeight-1)
 
-			color := img.At(srcX, srcY)
+			color := img.At(bounds.Min.X+srcX, bounds.Min.Y+srcY)
 
 			newImg.Set(x, y, color)
 		}
@@ -28,20 +39,80 @@ func Downscale(img image.Image, targetWidth int) image.Image {
 	return newImg
 }
 
+// DownscalePercent shrinks the image to the given percentage (1-100) of its
+// width, keeping the aspect ratio
+func DownscalePercent(img image.Image, percent int) image.Image {
+	return Downscale(img, proportionalSize(img.Bounds().Dx(), percent, 100))
+}
+
+// DownscaleFit shrinks the image to fit within maxWidth x maxHeight, keeping
+// the aspect ratio, so the more constraining limit decides the size. A limit
+// of 0 is ignored, and images that already fit are returned unchanged
+func DownscaleFit(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	width := fitWidth(bounds.Dx(), bounds.Dy(), maxWidth, maxHeight)
+	if width == bounds.Dx() {
+		return img
+	}
+	return Downscale(img, width)
+}
+
+// fitWidth returns the largest width, at most the original, whose
+// proportional height also fits. Limits of 0 are ignored
+func fitWidth(width, height, maxWidth, maxHeight int) int {
+	fit := width
+	if maxWidth > 0 {
+		fit = min(fit, maxWidth)
+	}
+	if maxHeight > 0 {
+		fit = min(fit, proportionalSize(width, maxHeight, height))
+		// Rounding the height up could still overshoot by a pixel
+		for fit > 1 && proportionalSize(height, fit, width) > maxHeight {
+			fit--
+		}
+	}
+	return fit
+}
+
+// proportionalSize returns size*num/den rounded to the nearest integer, using
+// 64-bit intermediates so large dimensions can't overflow. The result is at
+// least 1 so a thin image never collapses to zero pixels
+func proportionalSize(size, num, den int) int {
+	n := (int64(size)*int64(num)*2 + int64(den)) / (int64(den) * 2)
+	if n < 1 {
+		return 1
+	}
+	return int(n)
+}
+
 func UpscaleNearestNeighbor(img image.Image, scaleFactor int) image.Image {
+	return UpscaleBlocks(img, scaleFactor, scaleFactor)
+}
+
+// UpscaleBlocks enlarges every pixel into a blockWidth x blockHeight block,
+// which may be rectangular to mimic the non-square pixels of old hardware
+func UpscaleBlocks(img image.Image, blockWidth, blockHeight int) image.Image {
 	bounds := img.Bounds()
+
+	// A factor of 1 is a plain copy
+	if blockWidth == 1 && blockHeight == 1 {
+		newImg := newRGBA(bounds.Dx(), bounds.Dy())
+		draw.Draw(newImg, newImg.Bounds(), img, bounds.Min, draw.Src)
+		return newImg
+	}
+
 	width := bounds.Dx()
 	height := bounds.Dy()
 
-	newWidth := width * scaleFactor
-	newHeight := height * scaleFactor
+	newWidth := width * blockWidth
+	newHeight := height * blockHeight
 
-	newImg := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	newImg := newRGBA(newWidth, newHeight)
 
 	for y := 0; y < newHeight; y++ {
 		for x := 0; x < newWidth; x++ {
-			srcX := x / scaleFactor
-			srcY := y / scaleFactor
+			srcX := x / blockWidth
+			srcY := y / blockHeight
 
 			color := img.At(srcX, srcY)
 
@@ -50,4 +121,66 @@ func UpscaleNearestNeighbor(img image.Image, scaleFactor int) image.Image {
 	}
 
 	return newImg
-}
\ No newline at end of file
+}
+
+// UpscaleWithGap enlarges like UpscaleNearestNeighbor but fills the last gap
+// rows and columns of every block with gapColor, leaving scaleFactor-gap
+// sized tiles for a beaded look. A nil gapColor leaves the gaps transparent
+func UpscaleWithGap(img image.Image, scaleFactor, gap int, gapColor color.Color) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+	tile := scaleFactor - gap
+
+	newImg := newRGBA(width*scaleFactor, height*scaleFactor)
+	if gapColor != nil {
+		draw.Draw(newImg, newImg.Bounds(), image.NewUniform(gapColor), image.Point{}, draw.Src)
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			block := image.Rect(x*scaleFactor, y*scaleFactor, x*scaleFactor+tile, y*scaleFactor+tile)
+			draw.Draw(newImg, block, image.NewUniform(img.At(bounds.Min.X+x, bounds.Min.Y+y)), image.Point{}, draw.Src)
+		}
+	}
+
+	return newImg
+}
+
+// Pixelate replaces each blockSize x blockSize block with its average color,
+// keeping the original resolution
+func Pixelate(img image.Image, blockSize int) image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	newImg := newRGBA(width, height)
+
+	for by := 0; by < height; by += blockSize {
+		for bx := 0; bx < width; bx += blockSize {
+			endX := min(bx+blockSize, width)
+			endY := min(by+blockSize, height)
+
+			var sr, sg, sb, sa, n uint32
+			for y := by; y < endY; y++ {
+				for x := bx; x < endX; x++ {
+					r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+					sr += r >> 8
+					sg += g >> 8
+					sb += b >> 8
+					sa += a >> 8
+					n++
+				}
+			}
+
+			avg := color.RGBA{R: uint8(sr / n), G: uint8(sg / n), B: uint8(sb / n), A: uint8(sa / n)}
+			for y := by; y < endY; y++ {
+				for x := bx; x < endX; x++ {
+					newImg.SetRGBA(x, y, avg)
+				}
+			}
+		}
+	}
+
+	return newImg
+}
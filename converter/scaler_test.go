@@ -0,0 +1,196 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestUpscaleBlocks(t *testing.T) {
+	tests := []struct {
+		name                    string
+		blockWidth, blockHeight int
+		wantWidth, wantHeight   int
+	}{
+		{"square", 3, 3, 30, 15},
+		{"tall", 4, 8, 40, 40},
+		{"wide", 8, 4, 80, 20},
+		{"copy", 1, 1, 10, 5},
+	}
+
+	src := gradientImage(10, 5)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UpscaleBlocks(src, tt.blockWidth, tt.blockHeight)
+			if b := got.Bounds(); b.Dx() != tt.wantWidth || b.Dy() != tt.wantHeight {
+				t.Fatalf("got %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.wantWidth, tt.wantHeight)
+			}
+			// Every pixel of a block is its source pixel
+			for y := 0; y < tt.wantHeight; y++ {
+				for x := 0; x < tt.wantWidth; x++ {
+					want := color.NRGBAModel.Convert(src.At(x/tt.blockWidth, y/tt.blockHeight))
+					if c := color.NRGBAModel.Convert(got.At(x, y)); c != want {
+						t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, c, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUpscaleWithGap(t *testing.T) {
+	black := color.NRGBA{0, 0, 0, 255}
+	tests := []struct {
+		name     string
+		gapColor color.Color
+		want     color.NRGBA
+	}{
+		{"colored gap", black, black},
+		{"transparent gap", nil, color.NRGBA{}},
+	}
+
+	src := gradientImage(3, 2)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Blocks of 4 are 3x3 tiles followed by a 1 pixel gap
+			got := UpscaleWithGap(src, 4, 1, tt.gapColor)
+			if b := got.Bounds(); b.Dx() != 12 || b.Dy() != 8 {
+				t.Fatalf("got %dx%d, want 12x8", b.Dx(), b.Dy())
+			}
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 12; x++ {
+					want := tt.want
+					if x%4 < 3 && y%4 < 3 {
+						want = src.NRGBAAt(x/4, y/4)
+					}
+					if c := color.NRGBAModel.Convert(got.At(x, y)); c != want {
+						t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, c, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestProcessRectangularPixels(t *testing.T) {
+	config := testConfig()
+	config.PixelSize = 64
+	config.PixelW = 4
+	config.PixelH = 8
+
+	result, err := Process(gradientImage(640, 640), config)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	// Tall pixels halve the rows so the output stays square
+	if b := result.Small.Bounds(); b.Dx() != 64 || b.Dy() != 32 {
+		t.Errorf("grid is %dx%d, want 64x32", b.Dx(), b.Dy())
+	}
+	if result.FinalWidth != 256 || result.FinalHeight != 256 {
+		t.Errorf("output is %dx%d, want 256x256", result.FinalWidth, result.FinalHeight)
+	}
+}
+
+func TestProcessRectangularPixelsRejectsGap(t *testing.T) {
+	config := testConfig()
+	config.PixelW = 4
+	config.PixelH = 8
+	config.Gap = 1
+	if err := config.Validate(); err == nil {
+		t.Error("Validate accepted rectangular pixels with a gap")
+	}
+}
+
+func TestDownscaleDimensions(t *testing.T) {
+	tests := []struct {
+		name                  string
+		width, height, target int
+		wantHeight            int
+	}{
+		// Rounding rather than truncating keeps the aspect ratio exact
+		{"rounds half up", 100, 75, 30, 23},
+		{"exact", 200, 100, 50, 25},
+		{"thin never collapses", 1000, 1, 10, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Downscale(gradientImage(tt.width, tt.height), tt.target)
+			if b := got.Bounds(); b.Dx() != tt.target || b.Dy() != tt.wantHeight {
+				t.Errorf("got %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.target, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestDownscaleFit(t *testing.T) {
+	tests := []struct {
+		name                  string
+		width, height         int
+		wantWidth, wantHeight int
+	}{
+		{"landscape hits the width", 200, 100, 64, 32},
+		{"portrait hits the height", 100, 200, 24, 48},
+		{"already fits", 40, 30, 40, 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DownscaleFit(gradientImage(tt.width, tt.height), 64, 48)
+			if b := got.Bounds(); b.Dx() != tt.wantWidth || b.Dy() != tt.wantHeight {
+				t.Errorf("got %dx%d, want %dx%d within 64x48", b.Dx(), b.Dy(), tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestProportionalSize(t *testing.T) {
+	tests := []struct {
+		size, num, den int
+		want           int
+	}{
+		{75, 30, 100, 23}, // 22.5 rounds up
+		{74, 30, 100, 22}, // 22.2 rounds down
+		{1, 1, 1000, 1},   // never collapses to zero
+		{1 << 30, 1 << 30, 1 << 30, 1 << 30},
+	}
+
+	for _, tt := range tests {
+		if got := proportionalSize(tt.size, tt.num, tt.den); got != tt.want {
+			t.Errorf("proportionalSize(%d, %d, %d) = %d, want %d", tt.size, tt.num, tt.den, got, tt.want)
+		}
+	}
+}
+
+func TestDownscaleOffsetBounds(t *testing.T) {
+	// A sub-image doesn't start at the origin; sampling must stay inside it
+	full := gradientImage(20, 20)
+	sub := full.SubImage(image.Rect(5, 5, 15, 15))
+	got := Downscale(sub, 9)
+	if want := color.NRGBAModel.Convert(full.At(5, 5)); color.NRGBAModel.Convert(got.At(0, 0)) != want {
+		t.Errorf("top-left = %v, want %v", got.At(0, 0), want)
+	}
+}
+
+func TestDownscaleEdgeClamp(t *testing.T) {
+	// Targets just under the original size round the last sample past the
+	// edge without the clamp
+	tests := []struct {
+		width, height, target int
+	}{
+		{1001, 7, 1000},
+		{3, 3, 2},
+		{10, 10, 9},
+		{1, 1, 1},
+	}
+
+	for _, tt := range tests {
+		full := gradientImage(tt.width, tt.height)
+		got := Downscale(full, tt.target)
+		b := got.Bounds()
+		want := color.NRGBAModel.Convert(full.At(tt.width-1, tt.height-1))
+		if c := color.NRGBAModel.Convert(got.At(b.Max.X-1, b.Max.Y-1)); c != want {
+			t.Errorf("%dx%d to %d: bottom-right = %v, want the last source pixel %v", tt.width, tt.height, tt.target, c, want)
+		}
+	}
+}
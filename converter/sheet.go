@@ -0,0 +1,95 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+)
+
+// SplitGrid slices the image into cols x rows equally sized cells, returned
+// in row-major order. When the dimensions aren't evenly divisible the cells
+// are rounded up and the ones on the right and bottom edges are padded with
+// transparency
+func SplitGrid(img image.Image, cols, rows int) []image.Image {
+	bounds := img.Bounds()
+	cellW := (bounds.Dx() + cols - 1) / cols
+	cellH := (bounds.Dy() + rows - 1) / rows
+
+	cells := make([]image.Image, 0, cols*rows)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			cell := image.NewRGBA(image.Rect(0, 0, cellW, cellH))
+			src := image.Pt(bounds.Min.X+c*cellW, bounds.Min.Y+r*cellH)
+			draw.Draw(cell, cell.Bounds(), img, src, draw.Src)
+			cells = append(cells, cell)
+		}
+	}
+
+	return cells
+}
+
+// saveFrames splits img according to the sheet settings in config and writes
+// each cell as frame_<row>_<col>.png next to the output file
+func saveFrames(img image.Image, config Config) error {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if !config.SheetPad && (w%config.SheetCols != 0 || h%config.SheetRows != 0) {
+		return fmt.Errorf("%dx%d image doesn't divide into %dx%d cells, use padding to allow it", w, h, config.SheetCols, config.SheetRows)
+	}
+
+	dir := filepath.Dir(config.OutputFile)
+	for i, frame := range SplitGrid(img, config.SheetCols, config.SheetRows) {
+		name := filepath.Join(dir, fmt.Sprintf("frame_%d_%d.png", i/config.SheetCols, i%config.SheetCols))
+		if err := saveImage(name, frame, config.Overwrite); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SplitByColor returns one layer per palette entry, holding the pixels whose
+// nearest palette color it is and transparent elsewhere. Pixels keep their
+// own color, so drawing the layers over each other rebuilds img; fully
+// transparent pixels appear in no layer
+func SplitByColor(img image.Image, palette color.Palette) []image.Image {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	layers := make([]*image.NRGBA, len(palette))
+	for i := range layers {
+		layers[i] = image.NewNRGBA(image.Rect(0, 0, width, height))
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			if c.A == 0 || len(palette) == 0 {
+				continue
+			}
+			layers[nearestIndex(palette, c)].SetNRGBA(x, y, c)
+		}
+	}
+
+	out := make([]image.Image, len(layers))
+	for i, layer := range layers {
+		out[i] = layer
+	}
+	return out
+}
+
+// saveLayers writes each palette color of img as layer_<index>.png next to
+// the output file
+func saveLayers(img image.Image, palette color.Palette, config Config) error {
+	dir := filepath.Dir(config.OutputFile)
+	for i, layer := range SplitByColor(img, palette) {
+		name := filepath.Join(dir, fmt.Sprintf("layer_%02d.png", i))
+		if err := saveImage(name, layer, config.Overwrite); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
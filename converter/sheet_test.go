@@ -0,0 +1,46 @@
+package converter
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestSplitByColorRecombines(t *testing.T) {
+	palette := color.Palette{
+		color.NRGBA{20, 20, 40, 255},
+		color.NRGBA{200, 60, 60, 255},
+		color.NRGBA{240, 230, 180, 255},
+	}
+	src := image.NewNRGBA(image.Rect(0, 0, 9, 6))
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 9; x++ {
+			src.Set(x, y, palette[(x+2*y)%3])
+		}
+	}
+	// Transparent pixels belong to no layer and stay transparent
+	src.SetNRGBA(4, 3, color.NRGBA{})
+
+	layers := SplitByColor(src, palette)
+	if len(layers) != len(palette) {
+		t.Fatalf("got %d layers, want %d", len(layers), len(palette))
+	}
+	combined := image.NewNRGBA(src.Bounds())
+	for i, layer := range layers {
+		// Each layer holds only its own color
+		for y := 0; y < 6; y++ {
+			for x := 0; x < 9; x++ {
+				if c := color.NRGBAModel.Convert(layer.At(x, y)).(color.NRGBA); c.A != 0 && c != palette[i] {
+					t.Fatalf("layer %d has %v at (%d, %d)", i, c, x, y)
+				}
+			}
+		}
+		draw.Draw(combined, combined.Bounds(), layer, image.Point{}, draw.Over)
+	}
+
+	if !bytes.Equal(encodeTestPNG(t, combined), encodeTestPNG(t, src)) {
+		t.Error("the layers drawn over each other don't rebuild the image")
+	}
+}
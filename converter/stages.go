@@ -0,0 +1,202 @@
+package converter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"slices"
+)
+
+// processStage is one named step of ProcessContext. apply is nil when the
+// config leaves the stage off
+type processStage struct {
+	name  string
+	apply func(img image.Image) image.Image
+}
+
+// StageNames lists the stages of ProcessContext in their default order. In
+// mosaic mode the downscale stage averages blocks in place. par stretches
+// the image unevenly, so it follows lcd, whose cells must line up with the
+// upscaled blocks
+func StageNames() []string {
+	return []string{
+		"trim", "denoise", "blur", "sharpen", "lut",
+		"downscale", "posterize", "duotone", "quantize", "noise", "upscale",
+		"recolor", "cvd", "lcd", "par", "vignette", "colorkey", "alpha-threshold", "canvas", "pot", "mask",
+	}
+}
+
+// gridWidth is the pixel grid width for img: PixelSize, or derived from
+// Percent or MaxWidth and MaxHeight when those are set
+func (c Config) gridWidth(img image.Image) int {
+	if c.MaxWidth > 0 || c.MaxHeight > 0 {
+		return fitWidth(img.Bounds().Dx(), img.Bounds().Dy(), c.MaxWidth, c.MaxHeight)
+	}
+	if c.Percent > 0 {
+		return proportionalSize(img.Bounds().Dx(), c.Percent, 100)
+	}
+	return c.PixelSize
+}
+
+// blockSize is the size of the block each grid pixel is upscaled to:
+// PixelW by PixelH, either defaulting to Scale
+func (c Config) blockSize() (width, height int) {
+	width, height = c.Scale, c.Scale
+	if c.PixelW > 0 {
+		width = c.PixelW
+	}
+	if c.PixelH > 0 {
+		height = c.PixelH
+	}
+	return width, height
+}
+
+// processStages builds the stages the config enables, in the order set by
+// Stages.Order or the default one
+func (c Config) processStages() ([]processStage, error) {
+	config := c
+	pixelArt := config.Mode != ModeMosaic
+	blockWidth, blockHeight := config.blockSize()
+	core := func(img image.Image) Pipeline {
+		return config.Stages.merge(DefaultPipeline(config, config.gridWidth(img)))
+	}
+	when := func(enabled bool, apply func(image.Image) image.Image) func(image.Image) image.Image {
+		if !enabled {
+			return nil
+		}
+		return apply
+	}
+
+	stages := map[string]func(image.Image) image.Image{
+		"trim": when(config.Trim, func(img image.Image) image.Image {
+			return AutoTrim(img, config.TrimTolerance)
+		}),
+		"denoise": when(config.Denoise > 0, func(img image.Image) image.Image {
+			return MedianFilter(img, config.Denoise)
+		}),
+		"blur": when(config.Blur > 0, func(img image.Image) image.Image {
+			return GaussianBlur(img, config.Blur)
+		}),
+		"sharpen": when(config.Sharpen > 0, func(img image.Image) image.Image {
+			return Sharpen(img, config.Sharpen)
+		}),
+		"lut": when(config.LUT != nil, func(img image.Image) image.Image {
+			return ApplyLUT1D(img, config.LUT.R, config.LUT.G, config.LUT.B)
+		}),
+		"downscale": func(img image.Image) image.Image {
+			if !pixelArt {
+				// Size is the number of blocks across, matching the pixel art grid
+				width := config.gridWidth(img)
+				return Pixelate(img, (img.Bounds().Dx()+width-1)/width)
+			}
+			return core(img).Downscaler.Downscale(img)
+		},
+		"posterize": when(config.ValueLevels > 0, func(img image.Image) image.Image {
+			return PosterizeValue(img, config.ValueLevels)
+		}),
+		"duotone": when(config.DuotoneShadow != "", func(img image.Image) image.Image {
+			shadow, _ := ParseHexColor(config.DuotoneShadow)
+			highlight, _ := ParseHexColor(config.DuotoneHighlight)
+			return Duotone(img, shadow, highlight)
+		}),
+		"quantize": func(img image.Image) image.Image {
+			if !pixelArt {
+				return quantize(img, config)
+			}
+			return core(img).Quantizer.Quantize(img)
+		},
+		"noise": when(pixelArt && config.Noise > 0, func(img image.Image) image.Image {
+			return AddNoise(img, config.Noise, config.Seed)
+		}),
+		// Scale 1 keeps the small image as is, e.g. for use as a sprite,
+		// unless a custom upscaler wants to run anyway
+		"upscale": when(pixelArt && (blockWidth > 1 || blockHeight > 1 || config.Stages.Upscaler != nil), func(img image.Image) image.Image {
+			return core(img).Upscaler.Upscale(img)
+		}),
+		"par": when(config.PARWidth != config.PARHeight, func(img image.Image) image.Image {
+			return StretchPixelAspect(img, config.PARWidth, config.PARHeight)
+		}),
+		"recolor": when(len(config.RecolorFrom) > 0, func(img image.Image) image.Image {
+			if config.RecolorSnap {
+				return RemapPaletteNearest(img, config.RecolorFrom, config.RecolorTo)
+			}
+			return RemapPalette(img, config.RecolorFrom, config.RecolorTo)
+		}),
+		"cvd": when(config.CVD != "" && config.CVD != CVDNormal, func(img image.Image) image.Image {
+			return SimulateColorBlindness(img, config.CVD)
+		}),
+		"lcd": when(pixelArt && config.LCD, func(img image.Image) image.Image {
+			return LCDGrid(img, config.Scale)
+		}),
+		"vignette": when(config.Vignette > 0, func(img image.Image) image.Image {
+			return Vignette(img, config.Vignette)
+		}),
+		"colorkey": when(config.ColorKey != "", func(img image.Image) image.Image {
+			key, _ := ParseHexColor(config.ColorKey)
+			return ApplyColorKey(img, key, config.ColorKeyTolerance)
+		}),
+		"alpha-threshold": when(config.AlphaThreshold > 0, func(img image.Image) image.Image {
+			return ThresholdAlpha(img, uint8(config.AlphaThreshold))
+		}),
+		"canvas": when(config.CanvasWidth > 0, func(img image.Image) image.Image {
+			var bg color.Color
+			if config.CanvasBG != "" {
+				bg, _ = ParseHexColor(config.CanvasBG)
+			}
+			return PadToCanvas(img, config.CanvasWidth, config.CanvasHeight, bg)
+		}),
+		"pot": when(config.PowerOfTwo, func(img image.Image) image.Image {
+			padded, offset := PadToPowerOfTwo(img, config.PowerOfTwoCenter)
+			config.logger().Debug("Padded to power of two", "width", padded.Bounds().Dx(), "height", padded.Bounds().Dy(), "x", offset.X, "y", offset.Y)
+			return padded
+		}),
+		"mask": when(config.Mask != nil, func(img image.Image) image.Image {
+			return ApplyMask(img, config.Mask)
+		}),
+	}
+
+	order := config.Stages.Order
+	if len(order) == 0 {
+		order = StageNames()
+	}
+	if err := checkStageOrder(order); err != nil {
+		return nil, err
+	}
+
+	result := make([]processStage, 0, len(order))
+	for _, name := range order {
+		result = append(result, processStage{name: name, apply: stages[name]})
+	}
+	for _, name := range StageNames() {
+		if stages[name] != nil && !slices.Contains(order, name) {
+			return nil, fmt.Errorf("stage %s is enabled but missing from the stage order", name)
+		}
+	}
+	return result, nil
+}
+
+// checkStageOrder validates a custom stage order: known names, each at most
+// once, and downscale, quantize and upscale present with downscale before
+// upscale
+func checkStageOrder(order []string) error {
+	seen := make(map[string]int, len(order))
+	for i, name := range order {
+		if err := CheckEnum("stage", name, StageNames()); err != nil {
+			return err
+		}
+		if _, dup := seen[name]; dup {
+			return fmt.Errorf("stage %s appears more than once", name)
+		}
+		seen[name] = i
+	}
+
+	for _, name := range []string{"downscale", "quantize", "upscale"} {
+		if _, ok := seen[name]; !ok {
+			return fmt.Errorf("stage order must include %s", name)
+		}
+	}
+	if seen["downscale"] > seen["upscale"] {
+		return fmt.Errorf("downscale must come before upscale in the stage order")
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package converter
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCheckStageOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   []string
+		wantErr bool
+	}{
+		{"default", StageNames(), false},
+		{"minimal", []string{"downscale", "quantize", "upscale"}, false},
+		{"quantize before downscale", []string{"quantize", "downscale", "upscale"}, false},
+		{"unknown stage", []string{"downscale", "quantize", "upscale", "emboss"}, true},
+		{"duplicate", []string{"downscale", "quantize", "quantize", "upscale"}, true},
+		{"missing quantize", []string{"downscale", "upscale"}, true},
+		{"upscale first", []string{"upscale", "quantize", "downscale"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkStageOrder(tt.order)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkStageOrder() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessStageOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   []string
+		want    []string
+		wantErr bool
+	}{
+		{"default", nil, []string{"sharpen", "downscale", "quantize", "upscale"}, false},
+		{"sharpen the grid", []string{"downscale", "sharpen", "quantize", "upscale"}, []string{"downscale", "sharpen", "quantize", "upscale"}, false},
+		{"enabled stage left out", []string{"downscale", "quantize", "upscale"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Colors = 8
+			config.Sharpen = 1
+			config.Stages.Order = tt.order
+
+			result, err := Process(gradientImage(64, 64), config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Process() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			defer result.Release()
+
+			var ran []string
+			for _, stage := range result.Stages {
+				ran = append(ran, stage.Name)
+			}
+			if !slices.Equal(ran, tt.want) {
+				t.Errorf("stages ran %v, want %v", ran, tt.want)
+			}
+		})
+	}
+}
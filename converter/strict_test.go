@@ -0,0 +1,65 @@
+package converter
+
+import "testing"
+
+func TestStrictColors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(*Config)
+	}{
+		{"default quantize", func(c *Config) {}},
+		{"grid overlay", func(c *Config) {
+			c.PaletteName = "gameboy"
+			c.Colors = 4
+			c.Scale = 4
+			c.Gap = 1
+			c.GapColor = "123456"
+		}},
+		{"vignette", func(c *Config) {
+			c.Colors = 4
+			c.Vignette = 0.8
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			tt.config(&config)
+			config.StrictColors = true
+
+			result, err := Process(gradientImage(64, 64), config)
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if got := CountColors(result.Image); got > config.Colors {
+				t.Errorf("got %d colors, want at most %d", got, config.Colors)
+			}
+		})
+	}
+}
+
+func TestStrictColorsOverlayViolatesWithoutStrict(t *testing.T) {
+	config := testConfig()
+	config.PaletteName = "gameboy"
+	config.Colors = 4
+	config.Scale = 4
+	config.Gap = 1
+	config.GapColor = "123456"
+
+	result, err := Process(gradientImage(64, 64), config)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if got := CountColors(result.Image); got <= config.Colors {
+		t.Fatalf("got %d colors, want the overlay to exceed %d", got, config.Colors)
+	}
+}
+
+func TestStrictColorsNeedsLimit(t *testing.T) {
+	config := testConfig()
+	config.Colors = 0
+	config.StrictColors = true
+	if err := config.Validate(); err == nil {
+		t.Error("Validate accepted strict colors without a color count or palette")
+	}
+}
@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultOutputTemplate names batch outputs after their input
+const DefaultOutputTemplate = "{name}.png"
+
+// ExpandTemplate replaces each {placeholder} in tmpl with its value from
+// vars, failing on unknown placeholders and unbalanced braces
+func ExpandTemplate(tmpl string, vars map[string]string) (string, error) {
+	var b strings.Builder
+	rest := tmpl
+	for {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+		if rest[open] == '}' {
+			return "", fmt.Errorf("template %q has an unmatched '}'", tmpl)
+		}
+
+		b.WriteString(rest[:open])
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("template %q has an unclosed '{'", tmpl)
+		}
+
+		key := rest[open+1 : open+end]
+		value, ok := vars[key]
+		if !ok {
+			valid := make([]string, 0, len(vars))
+			for name := range vars {
+				valid = append(valid, "{"+name+"}")
+			}
+			sort.Strings(valid)
+			return "", fmt.Errorf("unknown placeholder {%s} in template %q; valid: %s", key, tmpl, strings.Join(valid, ", "))
+		}
+		b.WriteString(value)
+		rest = rest[open+end+1:]
+	}
+}
+
+// outputName expands the config's output template for one batch input
+func (c Config) outputName(input string) (string, error) {
+	tmpl := c.OutputTemplate
+	if tmpl == "" {
+		tmpl = DefaultOutputTemplate
+	}
+
+	base := filepath.Base(input)
+	ext := filepath.Ext(base)
+	return ExpandTemplate(tmpl, map[string]string{
+		"name":   strings.TrimSuffix(base, ext),
+		"ext":    strings.TrimPrefix(ext, "."),
+		"size":   strconv.Itoa(c.PixelSize),
+		"scale":  strconv.Itoa(c.Scale),
+		"colors": strconv.Itoa(c.Colors),
+	})
+}
@@ -0,0 +1,62 @@
+package converter
+
+import "testing"
+
+func TestExpandTemplate(t *testing.T) {
+	vars := map[string]string{"name": "cat", "size": "64"}
+	tests := []struct {
+		tmpl    string
+		want    string
+		wantErr bool
+	}{
+		{"{name}.png", "cat.png", false},
+		{"{name}_{size}px.png", "cat_64px.png", false},
+		{"out/{name}/{name}.png", "out/cat/cat.png", false},
+		{"fixed.png", "fixed.png", false},
+		{"{name.png", "", true},
+		{"name}.png", "", true},
+		{"{color}.png", "", true},
+		{"{}.png", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			got, err := ExpandTemplate(tt.tmpl, vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandTemplate() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	tests := []struct {
+		name  string
+		tmpl  string
+		input string
+		want  string
+	}{
+		{"default", "", "photos/cat.jpg", "cat.png"},
+		{"all placeholders", "{name}-{ext}-{size}x{scale}-{colors}.png", "cat.jpg", "cat-jpg-16x2-8.png"},
+		{"no extension", "{name}.gif", "dir/README", "README.gif"},
+		{"dotted name", "{name}.png", "a.b.c.jpeg", "a.b.c.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig()
+			config.Colors = 8
+			config.OutputTemplate = tt.tmpl
+			got, err := config.outputName(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("outputName(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
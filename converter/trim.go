@@ -0,0 +1,94 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// AutoTrim crops uniform margins. The background is the color shared by most
+// of the four corners; rows and columns at the edges whose pixels are all
+// within tolerance of it (per channel) are removed. An image that is entirely
+// background is returned unchanged
+func AutoTrim(img image.Image, tolerance int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return img
+	}
+
+	bg := cornerColor(img)
+	isBackground := func(x, y int) bool {
+		c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+		return channelDiff(c.R, bg.R) <= tolerance &&
+			channelDiff(c.G, bg.G) <= tolerance &&
+			channelDiff(c.B, bg.B) <= tolerance &&
+			channelDiff(c.A, bg.A) <= tolerance
+	}
+	rowIsBackground := func(y, minX, maxX int) bool {
+		for x := minX; x < maxX; x++ {
+			if !isBackground(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+	colIsBackground := func(x, minY, maxY int) bool {
+		for y := minY; y < maxY; y++ {
+			if !isBackground(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+
+	crop := bounds
+	for crop.Min.Y < crop.Max.Y && rowIsBackground(crop.Min.Y, crop.Min.X, crop.Max.X) {
+		crop.Min.Y++
+	}
+	if crop.Min.Y == crop.Max.Y {
+		return img
+	}
+	for rowIsBackground(crop.Max.Y-1, crop.Min.X, crop.Max.X) {
+		crop.Max.Y--
+	}
+	for colIsBackground(crop.Min.X, crop.Min.Y, crop.Max.Y) {
+		crop.Min.X++
+	}
+	for colIsBackground(crop.Max.X-1, crop.Min.Y, crop.Max.Y) {
+		crop.Max.X--
+	}
+
+	if crop == bounds {
+		return img
+	}
+
+	newImg := newRGBA(crop.Dx(), crop.Dy())
+	draw.Draw(newImg, newImg.Bounds(), img, crop.Min, draw.Src)
+	return newImg
+}
+
+// cornerColor returns the color found in most of the image's corners,
+// preferring the top-left one on a tie
+func cornerColor(img image.Image) color.NRGBA {
+	b := img.Bounds()
+	corners := []color.NRGBA{
+		color.NRGBAModel.Convert(img.At(b.Min.X, b.Min.Y)).(color.NRGBA),
+		color.NRGBAModel.Convert(img.At(b.Max.X-1, b.Min.Y)).(color.NRGBA),
+		color.NRGBAModel.Convert(img.At(b.Min.X, b.Max.Y-1)).(color.NRGBA),
+		color.NRGBAModel.Convert(img.At(b.Max.X-1, b.Max.Y-1)).(color.NRGBA),
+	}
+
+	best, bestCount := corners[0], 0
+	for _, c := range corners {
+		count := 0
+		for _, other := range corners {
+			if other == c {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = c, count
+		}
+	}
+	return best
+}
@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// framedImage returns a width x height image of bg with a rect of fg
+func framedImage(width, height int, bg, fg color.Color, rect image.Rectangle) *image.NRGBA {
+	img := solidImage(width, height, bg)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.Set(x, y, fg)
+		}
+	}
+	return img
+}
+
+func TestAutoTrim(t *testing.T) {
+	white := color.NRGBA{255, 255, 255, 255}
+	red := color.NRGBA{200, 0, 0, 255}
+	tests := []struct {
+		name      string
+		img       image.Image
+		tolerance int
+		want      image.Point
+	}{
+		{"margins", framedImage(20, 10, white, red, image.Rect(4, 2, 14, 7)), 0, image.Pt(10, 5)},
+		{"no margins", framedImage(20, 10, white, red, image.Rect(0, 0, 20, 10)), 0, image.Pt(20, 10)},
+		{"corner content", framedImage(20, 10, white, red, image.Rect(0, 0, 15, 5)), 0, image.Pt(15, 5)},
+		{"all background", solidImage(20, 10, white), 0, image.Pt(20, 10)},
+		{"off-white outside tolerance", framedImage(20, 10, white, color.NRGBA{250, 250, 250, 255}, image.Rect(4, 2, 14, 7)), 2, image.Pt(10, 5)},
+		{"off-white within tolerance", framedImage(20, 10, white, color.NRGBA{250, 250, 250, 255}, image.Rect(4, 2, 14, 7)), 8, image.Pt(20, 10)},
+		{"offset bounds", framedImage(20, 10, white, red, image.Rect(4, 2, 14, 7)).SubImage(image.Rect(2, 1, 18, 9)), 0, image.Pt(10, 5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AutoTrim(tt.img, tt.tolerance)
+			if size := got.Bounds().Size(); size != tt.want {
+				t.Errorf("size = %v, want %v", size, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoTrimKeepsContent(t *testing.T) {
+	red := color.NRGBA{200, 0, 0, 255}
+	img := framedImage(20, 10, color.White, red, image.Rect(4, 2, 14, 7))
+	got := AutoTrim(img, 0)
+	b := got.Bounds()
+	for _, p := range []image.Point{b.Min, {b.Max.X - 1, b.Max.Y - 1}} {
+		if c := color.NRGBAModel.Convert(got.At(p.X, p.Y)); c != red {
+			t.Errorf("pixel %v = %v, want %v", p, c, red)
+		}
+	}
+}
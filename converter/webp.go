@@ -0,0 +1,273 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"time"
+
+	"golang.org/x/image/webp"
+)
+
+const (
+	// maxDecodePixels caps the size of a canvas or frame decoded from
+	// untrusted input, about 256MB as NRGBA
+	maxDecodePixels = 64 << 20
+	// maxAnimationPixels caps the frames times canvas pixels an animation
+	// decodes into, since every frame keeps a full copy of the canvas
+	maxAnimationPixels = 256 << 20
+)
+
+// checkDecodePixels rejects images over maxDecodePixels before anything is
+// allocated for them
+func checkDecodePixels(width, height int) error {
+	if int64(width)*int64(height) > maxDecodePixels {
+		return fmt.Errorf("image of %dx%d is over the limit of %d pixels", width, height, maxDecodePixels)
+	}
+	return nil
+}
+
+// Frame is one frame of an animation and how long it is shown
+type Frame struct {
+	Image image.Image
+	Delay time.Duration
+}
+
+// ConvertAnimatedWebP decodes every frame of an animated WebP, composited
+// onto the full canvas, and runs each through the conversion pipeline. A
+// still WebP yields a single frame with no delay.
+//
+// Go has no animated WebP encoder, so choosing an output container is left to
+// the caller; Convert writes the frames as an animated GIF
+func ConvertAnimatedWebP(ctx context.Context, r io.Reader, config Config) ([]Frame, error) {
+	frames, err := DecodeAnimatedWebP(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := processFrames(ctx, frames, config); err != nil {
+		return nil, err
+	}
+	return frames, nil
+}
+
+// processFrames runs every frame through the pipeline in place
+func processFrames(ctx context.Context, frames []Frame, config Config) error {
+	for i := range frames {
+		result, err := ProcessContext(ctx, frames[i].Image, config)
+		if err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+		frames[i].Image = result.Image
+	}
+	return nil
+}
+
+// DecodeAnimatedWebP decodes all frames of a WebP file. Animated files are
+// composited frame by frame following each frame's blend and dispose flags,
+// so every returned image covers the whole canvas
+func DecodeAnimatedWebP(r io.Reader) ([]Frame, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := readWebPChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	vp8x, animated := findChunk(chunks, "VP8X"), findChunk(chunks, "ANIM") != nil
+	if !animated || vp8x == nil {
+		config, err := webp.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if err := checkDecodePixels(config.Width, config.Height); err != nil {
+			return nil, err
+		}
+		img, err := webp.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return []Frame{{Image: img}}, nil
+	}
+
+	if len(vp8x.data) < 10 {
+		return nil, errors.New("webp: invalid VP8X chunk")
+	}
+	canvasW := int(uint24(vp8x.data[4:])) + 1
+	canvasH := int(uint24(vp8x.data[7:])) + 1
+	if err := checkDecodePixels(canvasW, canvasH); err != nil {
+		return nil, err
+	}
+	frameCount := 0
+	for _, chunk := range chunks {
+		if chunk.id == "ANMF" {
+			frameCount++
+		}
+	}
+	if int64(frameCount)*int64(canvasW)*int64(canvasH) > maxAnimationPixels {
+		return nil, fmt.Errorf("webp: %d frames of %dx%d are over the limit of %d pixels", frameCount, canvasW, canvasH, maxAnimationPixels)
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, canvasW, canvasH))
+
+	var frames []Frame
+	for _, chunk := range chunks {
+		if chunk.id != "ANMF" {
+			continue
+		}
+		if len(chunk.data) < 16 {
+			return nil, errors.New("webp: invalid ANMF chunk")
+		}
+
+		x := int(uint24(chunk.data[0:])) * 2
+		y := int(uint24(chunk.data[3:])) * 2
+		w := int(uint24(chunk.data[6:])) + 1
+		h := int(uint24(chunk.data[9:])) + 1
+		delay := time.Duration(uint24(chunk.data[12:])) * time.Millisecond
+		flags := chunk.data[15]
+		if x+w > canvasW || y+h > canvasH {
+			return nil, fmt.Errorf("webp: frame %d lies outside the canvas", len(frames))
+		}
+		noBlend := flags&0x02 != 0
+		dispose := flags&0x01 != 0
+
+		frameImg, err := decodeWebPFrame(chunk.data[16:], w, h)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", len(frames), err)
+		}
+
+		rect := image.Rect(x, y, x+w, y+h)
+		op := draw.Over
+		if noBlend {
+			op = draw.Src
+		}
+		draw.Draw(canvas, rect, frameImg, frameImg.Bounds().Min, op)
+
+		snapshot := image.NewNRGBA(canvas.Bounds())
+		copy(snapshot.Pix, canvas.Pix)
+		frames = append(frames, Frame{Image: snapshot, Delay: delay})
+
+		if dispose {
+			draw.Draw(canvas, rect, image.Transparent, image.Point{}, draw.Src)
+		}
+	}
+
+	if len(frames) == 0 {
+		return nil, errors.New("webp: animation has no frames")
+	}
+
+	return frames, nil
+}
+
+type webpChunk struct {
+	id   string
+	data []byte
+}
+
+// readWebPChunks splits a RIFF WEBP file into its top-level chunks, or the
+// sub-chunks when given the payload of an ANMF chunk
+func readWebPChunks(data []byte) ([]webpChunk, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("webp: not a RIFF WEBP file")
+	}
+	return splitChunks(data[12:])
+}
+
+func splitChunks(data []byte) ([]webpChunk, error) {
+	var chunks []webpChunk
+	for len(data) >= 8 {
+		id := string(data[0:4])
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		if size < 0 || 8+size > len(data) {
+			return nil, fmt.Errorf("webp: truncated %q chunk", id)
+		}
+		chunks = append(chunks, webpChunk{id: id, data: data[8 : 8+size]})
+
+		// Chunks are padded to an even size
+		next := 8 + size + size&1
+		if next > len(data) {
+			break
+		}
+		data = data[next:]
+	}
+	return chunks, nil
+}
+
+func findChunk(chunks []webpChunk, id string) *webpChunk {
+	for i := range chunks {
+		if chunks[i].id == id {
+			return &chunks[i]
+		}
+	}
+	return nil
+}
+
+// decodeWebPFrame wraps the bitstream chunks of an ANMF frame in a standalone
+// WebP file so the still-image decoder can read it
+func decodeWebPFrame(frameData []byte, w, h int) (image.Image, error) {
+	sub, err := splitChunks(frameData)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	if alph := findChunk(sub, "ALPH"); alph != nil {
+		// Lossy frames with alpha need a VP8X header announcing it
+		vp8x := make([]byte, 10)
+		vp8x[0] = 0x10
+		putUint24(vp8x[4:], uint32(w-1))
+		putUint24(vp8x[7:], uint32(h-1))
+		writeChunk(&body, "VP8X", vp8x)
+		writeChunk(&body, "ALPH", alph.data)
+	}
+
+	bitstream := findChunk(sub, "VP8 ")
+	if bitstream == nil {
+		bitstream = findChunk(sub, "VP8L")
+	}
+	if bitstream == nil {
+		return nil, errors.New("webp: frame has no image data")
+	}
+	writeChunk(&body, bitstream.id, bitstream.data)
+
+	var file bytes.Buffer
+	file.WriteString("RIFF")
+	binary.Write(&file, binary.LittleEndian, uint32(4+body.Len()))
+	file.WriteString("WEBP")
+	file.Write(body.Bytes())
+
+	// The bitstream carries its own size, which needn't match the frame's
+	config, err := webp.DecodeConfig(bytes.NewReader(file.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width != w || config.Height != h {
+		return nil, fmt.Errorf("webp: frame data is %dx%d, not the declared %dx%d", config.Width, config.Height, w, h)
+	}
+	return webp.Decode(&file)
+}
+
+func writeChunk(buf *bytes.Buffer, id string, data []byte) {
+	buf.WriteString(id)
+	binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	if len(data)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
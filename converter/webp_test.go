@@ -0,0 +1,113 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// animatedWebP builds an animated WebP with a canvasW x canvasH canvas and
+// one frame per offset, each frame being the bitstream of still.webp
+func animatedWebP(t *testing.T, canvasW, canvasH int, offsets ...[2]int) []byte {
+	t.Helper()
+	still, err := os.ReadFile("testdata/still.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := webp.DecodeConfig(bytes.NewReader(still))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := readWebPChunks(still)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bitstream := findChunk(chunks, "VP8L")
+
+	var body bytes.Buffer
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x02 // animation
+	putUint24(vp8x[4:], uint32(canvasW-1))
+	putUint24(vp8x[7:], uint32(canvasH-1))
+	writeChunk(&body, "VP8X", vp8x)
+	writeChunk(&body, "ANIM", make([]byte, 6))
+
+	for _, offset := range offsets {
+		var frame bytes.Buffer
+		header := make([]byte, 16)
+		putUint24(header[0:], uint32(offset[0]/2))
+		putUint24(header[3:], uint32(offset[1]/2))
+		putUint24(header[6:], uint32(config.Width-1))
+		putUint24(header[9:], uint32(config.Height-1))
+		putUint24(header[12:], 100)
+		frame.Write(header)
+		writeChunk(&frame, bitstream.id, bitstream.data)
+		writeChunk(&body, "ANMF", frame.Bytes())
+	}
+
+	var file bytes.Buffer
+	file.WriteString("RIFF")
+	binary.Write(&file, binary.LittleEndian, uint32(4+body.Len()))
+	file.WriteString("WEBP")
+	file.Write(body.Bytes())
+	return file.Bytes()
+}
+
+func TestDecodeAnimatedWebP(t *testing.T) {
+	data := animatedWebP(t, 200, 200, [2]int{0, 0}, [2]int{10, 10})
+	frames, err := DecodeAnimatedWebP(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DecodeAnimatedWebP: %v", err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	for i, frame := range frames {
+		if b := frame.Image.Bounds(); b.Dx() != 200 || b.Dy() != 200 {
+			t.Errorf("frame %d is %dx%d, want the 200x200 canvas", i, b.Dx(), b.Dy())
+		}
+	}
+}
+
+func TestDecodeAnimatedWebPLimits(t *testing.T) {
+	many := make([][2]int, 20)
+	tests := []struct {
+		name string
+		data func(t *testing.T) []byte
+	}{
+		{"huge canvas", func(t *testing.T) []byte {
+			return animatedWebP(t, 1<<24, 1<<24, [2]int{0, 0})
+		}},
+		{"frames times canvas", func(t *testing.T) []byte {
+			return animatedWebP(t, 4096, 4096, many...)
+		}},
+		{"frame outside canvas", func(t *testing.T) []byte {
+			return animatedWebP(t, 200, 200, [2]int{190, 0})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeAnimatedWebP(bytes.NewReader(tt.data(t))); err == nil {
+				t.Error("DecodeAnimatedWebP accepted the file")
+			}
+		})
+	}
+}
+
+func TestDecodeAnimatedWebPStill(t *testing.T) {
+	still, err := os.ReadFile("testdata/still.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, err := DecodeAnimatedWebP(bytes.NewReader(still))
+	if err != nil {
+		t.Fatalf("DecodeAnimatedWebP: %v", err)
+	}
+	if len(frames) != 1 || frames[0].Delay != 0 {
+		t.Errorf("got %d frames, want a single still frame", len(frames))
+	}
+}
@@ -1,3 +1,5 @@
 module pixgrid
 
 go 1.25.1
+
+require golang.org/x/image v0.45.0
@@ -3,31 +3,143 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"pixgrid/converter"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func main() {
-	inputFile := flag.String("input", "", "Input image file (PNG or JPG)")
-	outputFile := flag.String("output", "output.png", "Output image file")
-	pixelSize := flag.Int("size", 64, "Target width in pixels (height scales proportionally)")
-	scale := flag.Int("scale", 8, "Upscale factor (how much to enlarge the pixelated image)")
-	colors := flag.Int("colors", 32, "Number of colors in the palette (0 = no quantization)")
-	
+	config := converter.DefaultConfig()
+
+	flag.StringVar(&config.InputFile, "input", "", "Input image file (PNG, JPG, GIF, WebP or ICO) or http(s) URL")
+	flag.IntVar(&config.FetchAttempts, "fetch-attempts", 3, "Attempts for a URL -input on network errors or 5xx responses")
+	flag.DurationVar(&config.URLTimeout, "url-timeout", 30*time.Second, "Time limit for each attempt at a URL -input, from connecting to the last byte")
+	flag.StringVar(&config.OutputFile, "output", config.OutputFile, "Output image file")
+	flag.BoolVar(&config.Overwrite, "overwrite", false, "Replace existing output files instead of failing, or skipping them in batch mode")
+	flag.BoolVar(&config.Overwrite, "force", false, "Same as -overwrite")
+	flag.IntVar(&config.MaxFileSize, "max-filesize", 0, "Fit JPEG output within this many bytes by lowering its quality (0 = no limit)")
+	flag.StringVar(&config.Format, "format", config.Format, "Output format: "+strings.Join(converter.Formats(), ", "))
+	flag.BoolVar(&config.ANSI, "ansi", false, "Color -format txt output with ANSI 24-bit escape codes")
+	flag.StringVar(&config.CompareFile, "compare", "", "Also write the original and the result side by side to this file")
+	flag.BoolVar(&config.CountColors, "count-colors", false, "Print the number of distinct colors in the result")
+	flag.BoolVar(&config.StrictColors, "strict-colors", false, "Guarantee the output stays within -colors or the palette, snapping back colors added by later effects")
+	flag.BoolVar(&config.NormalMap, "normalmap", false, "Also write a normal map of the result as <output>_normal.<ext>")
+	flag.Float64Var(&config.NormalStrength, "normal-strength", config.NormalStrength, "Bumpiness of -normalmap")
+	flag.StringVar(&config.PaletteExport, "palette-export", "", "Also write the palette quantized to as a GIMP .gpl or JASC .pal file")
+	flag.BoolVar(&config.Layers, "layers", false, "Also write one layer_NN.png mask per palette color next to the output")
+	flag.IntVar(&config.PixelSize, "size", config.PixelSize, "Target width in pixels (height scales proportionally)")
+	flag.IntVar(&config.Percent, "percent", 0, "Target width as a percentage (1-100) of the original, instead of -size")
+	flag.IntVar(&config.MaxWidth, "max-width", 0, "Fit the pixel grid within this width, instead of -size")
+	flag.IntVar(&config.MaxHeight, "max-height", 0, "Fit the pixel grid within this height, instead of -size")
+	flag.IntVar(&config.Scale, "scale", config.Scale, "Upscale factor (how much to enlarge the pixelated image)")
+	flag.IntVar(&config.PixelW, "pixel-w", 0, "Width of the block each pixel is upscaled to, for rectangular pixels (default -scale)")
+	flag.IntVar(&config.PixelH, "pixel-h", 0, "Height of the block each pixel is upscaled to, for rectangular pixels (default -scale)")
+	flag.Var(colorsFlag{&config.Colors}, "colors", "Number of colors in the palette, auto to pick one per image or 0 for no quantization")
+	flag.StringVar(&config.Sample, "sample", config.Sample, "How downscaling picks each pixel: "+strings.Join(converter.SampleModes(), ", "))
+	flag.BoolVar(&config.Trim, "trim", false, "Crop uniform borders before downscaling")
+	flag.IntVar(&config.TrimTolerance, "trim-tolerance", 10, "Per-channel tolerance when detecting borders for -trim")
+	flag.IntVar(&config.Denoise, "denoise", 0, "Median filter radius applied before downscaling to remove speckles (0 = disabled)")
+	flag.Float64Var(&config.Blur, "blur", 0, "Gaussian blur radius applied before downscaling (0 = disabled)")
+	flag.Float64Var(&config.Vignette, "vignette", 0, "Vignette strength from 0 to 1 applied after upscaling (0 = disabled)")
+	flag.Float64Var(&config.Noise, "noise", 0, "Per-pixel noise amount from 0 to 1 added at the pixel grid scale (0 = disabled)")
+	flag.Int64Var(&config.Seed, "seed", 0, "Random seed for -noise")
+	flag.StringVar(&config.CVD, "cvd", "", "Simulate color blindness on the quantized result: "+strings.Join(converter.CVDModes(), ", "))
+	flag.IntVar(&config.Gap, "gap", 0, "Pixels of spacing between upscaled blocks for a beaded look (0 = disabled)")
+	flag.StringVar(&config.GapColor, "gap-color", "", "Hex color filling the -gap spacing (default transparent)")
+	flag.BoolVar(&config.LCD, "lcd", false, "Render each upscaled pixel as RGB LCD subpixel stripes (needs -scale 3 or more)")
+	flag.StringVar(&config.LUTFile, "lut", "", "Tone curve applied before downscaling: CSV or JSON with 256 entries per channel")
+	flag.Float64Var(&config.Sharpen, "sharpen", 0, "Unsharp mask amount applied before downscaling (0 = disabled)")
+	flag.StringVar(&config.PaletteName, "palette", "", "Built-in palette to quantize to, overrides -colors: "+strings.Join(converter.PaletteNames(), ", "))
+	flag.StringVar(&config.PaletteFile, "palette-file", "", "Palette file (.gpl or hex list) to quantize to, overrides -colors")
+	flag.StringVar(&config.PaletteFrom, "palette-from", "", "Reference image whose median-cut palette of -colors entries the input is quantized to")
+	flag.Var(recolorFlag{&config.RecolorFromFile, &config.RecolorToFile}, "recolor", "Swap colors of one palette file for another by index, as from.gpl,to.gpl")
+	flag.BoolVar(&config.RecolorSnap, "recolor-snap", false, "Snap colors missing from the -recolor source palette to its nearest entry")
+	flag.BoolVar(&config.BlackWhite, "bw", false, "1-bit black and white with ordered (Bayer) dithering, for an early Mac look")
+	flag.IntVar(&config.BayerSize, "bw-matrix", 4, "Bayer matrix size for -bw: 2, 4 or 8")
+	flag.BoolVar(&config.Flat, "flat", false, "Flat colors for logos and text: median-cut palette, nearest color, no dithering")
+	flag.StringVar(&config.Dither, "dither", config.Dither, "Dithering mode: "+strings.Join(converter.DitherModes(), ", "))
+	flag.Var(rangeFlag{&config.DitherLow, &config.DitherHigh}, "dither-range", "Only dither pixels with a luminance outside lo,hi (0-255), e.g. 64,192")
+	flag.StringVar(&config.ColorKey, "colorkey", "", "Make pixels of this hex color (#RGB, #RRGGBB or #RRGGBBAA) transparent, e.g. FF00FF")
+	flag.IntVar(&config.ColorKeyTolerance, "colorkey-tolerance", 8, "Per-channel tolerance when matching -colorkey")
+	flag.IntVar(&config.AlphaThreshold, "alpha-threshold", 0, "Make alpha binary: opaque at or above this value, transparent below (0 = disabled)")
+	flag.IntVar(&config.ValueLevels, "value-levels", 0, "Posterize brightness (HSV value) into this many bands, keeping hue and saturation (0 = disabled)")
+	flag.Var(duotoneFlag{&config.DuotoneShadow, &config.DuotoneHighlight}, "duotone", "Map brightness onto a gradient between two hex colors, shadow,highlight, e.g. 1B1B3A,F4D35E")
+	flag.IntVar(&config.AlphaLevels, "alpha-levels", 0, "Reduce alpha to this many steps while quantizing (0 = passthrough)")
+	flag.Var(ratioFlag{&config.PARWidth, &config.PARHeight}, "par", "Pixel aspect ratio W:H of the input to correct after upscaling, e.g. 2:1 for pixels twice as wide as tall (default: read from the file, else 1:1)")
+	flag.Var(canvasFlag{&config.CanvasWidth, &config.CanvasHeight}, "canvas", "Center the output on a fixed WxH canvas, e.g. 128x128")
+	flag.StringVar(&config.CanvasBG, "canvas-bg", "", "Hex color (#RGB, #RRGGBB or #RRGGBBAA) filling the -canvas padding (default transparent)")
+	flag.StringVar(&config.MaskFile, "mask", "", "Grayscale image whose luminance becomes the output alpha, stretched to fit")
+	flag.BoolVar(&config.PowerOfTwo, "pot", false, "Pad the output with transparency to power-of-two dimensions, art in the top-left")
+	flag.BoolVar(&config.PowerOfTwoCenter, "pot-center", false, "Center the art when padding with -pot")
+	flag.IntVar(&config.SheetCols, "sheet-cols", 0, "Split the output into this many columns of frame_r_c.png files")
+	flag.IntVar(&config.SheetRows, "sheet-rows", 0, "Split the output into this many rows of frame_r_c.png files")
+	flag.BoolVar(&config.SheetPad, "sheet-pad", false, "Pad frames with transparency when the output doesn't divide evenly")
+	flag.Var(stagesFlag{&config.Stages.Order}, "stages", "Comma-separated stage order, e.g. lut,downscale,quantize,upscale,vignette; stages: "+strings.Join(converter.StageNames(), ", "))
+	sourceType := flag.String("type", "", "Kind of source image, setting sampling, dithering and color defaults: "+strings.Join(converter.SourceTypeNames(), ", "))
+	preset := flag.String("preset", "", "Named preset setting palette, dithering, size and scale: "+strings.Join(converter.PresetNames(), ", "))
+	configFile := flag.String("config", "", "JSON config file, explicit flags override its values")
+	inputDir := flag.String("input-dir", "", "Convert every PNG/JPG in this directory (batch mode)")
+	outputDir := flag.String("output-dir", "output", "Output directory for batch mode")
+	flag.StringVar(&config.OutputTemplate, "outtemplate", converter.DefaultOutputTemplate, "Batch output file name with {name}, {ext}, {size}, {scale} and {colors} placeholders")
+	quiet := flag.Bool("quiet", false, "Only print errors")
+	verbose := flag.Bool("verbose", false, "Also print the time taken by each stage")
+
 	flag.Parse()
 
-	if *inputFile == "" {
-		fmt.Println("Error: -input flag is required")
+	if err := applyLayers(&config, *sourceType, *preset, *configFile); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *quiet && *verbose {
+		fmt.Println("Error: -quiet and -verbose are mutually exclusive")
+		os.Exit(1)
+	}
+
+	level := slog.LevelInfo
+	if *quiet {
+		level = slog.LevelError
+	} else if *verbose {
+		level = slog.LevelDebug
+	}
+	config.Logger = newLogger(level)
+
+	if config.InputFile == "" && *inputDir == "" {
+		fmt.Println("Error: -input or -input-dir flag is required")
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	config := converter.Config{
-		InputFile:  *inputFile,
-		OutputFile: *outputFile,
-		PixelSize:  *pixelSize,
-		Scale:      *scale,
-		Colors:     *colors,
+	if flagSet("size") && flagSet("percent") {
+		fmt.Println("Error: -size and -percent are mutually exclusive")
+		os.Exit(1)
+	}
+	if (flagSet("size") || flagSet("percent")) && (flagSet("max-width") || flagSet("max-height")) {
+		fmt.Println("Error: -max-width and -max-height can't be combined with -size or -percent")
+		os.Exit(1)
+	}
+
+	if err := config.Validate(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *inputDir != "" {
+		manifest, err := converter.ConvertBatch(config, *inputDir, *outputDir)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		converted := len(manifest.Files) - manifest.Failed() - manifest.Skipped()
+		config.Logger.Info("Batch finished", "converted", converted, "skipped", manifest.Skipped(), "failed", manifest.Failed())
+		if manifest.Failed() > 0 {
+			os.Exit(1)
+		}
+		return
 	}
 
 	if err := converter.Convert(config); err != nil {
@@ -35,5 +147,252 @@ func main() {
 		os.Exit(1)
 	}
 
-	fmt.Println("Conversion completed successfully!")
-}
\ No newline at end of file
+	config.Logger.Info("Conversion completed successfully!")
+}
+
+// newLogger writes plain "level=... msg=..." lines without timestamps, which
+// are just noise for a one-shot CLI run
+func newLogger(level slog.Level) *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// applyLayers resolves the final config: flag defaults, then the source type,
+// the preset and the JSON config file, then the flags given on the command
+// line
+func applyLayers(config *converter.Config, sourceType, preset, configFile string) error {
+	if sourceType == "" && preset == "" && configFile == "" {
+		return nil
+	}
+
+	explicit := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	if sourceType != "" {
+		if err := converter.ApplySourceType(config, sourceType); err != nil {
+			return err
+		}
+	}
+
+	if preset != "" {
+		if err := converter.ApplyPreset(config, preset); err != nil {
+			return err
+		}
+	}
+
+	if configFile != "" {
+		if err := converter.LoadConfigFile(configFile, config); err != nil {
+			return err
+		}
+	}
+
+	// The flags are bound to config, so setting them again restores the
+	// command-line values over the type's, preset's and file's
+	for name, value := range explicit {
+		if err := flag.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flagSet reports whether the named flag was given on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// canvasFlag parses a "WxH" size into two config fields
+type canvasFlag struct {
+	width, height *int
+}
+
+func (f canvasFlag) String() string {
+	if f.width == nil || *f.width == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", *f.width, *f.height)
+}
+
+func (f canvasFlag) Set(value string) error {
+	w, h, ok := strings.Cut(strings.ToLower(value), "x")
+	if !ok {
+		return fmt.Errorf("expected WxH, got %q", value)
+	}
+	width, err := strconv.Atoi(w)
+	if err != nil {
+		return fmt.Errorf("invalid width %q", w)
+	}
+	height, err := strconv.Atoi(h)
+	if err != nil {
+		return fmt.Errorf("invalid height %q", h)
+	}
+	*f.width, *f.height = width, height
+	return nil
+}
+
+// ratioFlag parses a "W:H" ratio
+type ratioFlag struct {
+	width, height *int
+}
+
+func (f ratioFlag) String() string {
+	if f.width == nil || *f.width == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", *f.width, *f.height)
+}
+
+func (f ratioFlag) Set(value string) error {
+	w, h, ok := strings.Cut(value, ":")
+	if !ok {
+		return fmt.Errorf("expected W:H, got %q", value)
+	}
+	width, err := strconv.Atoi(w)
+	if err != nil || width <= 0 {
+		return fmt.Errorf("invalid width %q", w)
+	}
+	height, err := strconv.Atoi(h)
+	if err != nil || height <= 0 {
+		return fmt.Errorf("invalid height %q", h)
+	}
+	*f.width, *f.height = width, height
+	return nil
+}
+
+// colorsFlag parses a color count, or "auto" for converter.ColorsAuto
+type colorsFlag struct {
+	colors *int
+}
+
+func (f colorsFlag) String() string {
+	if f.colors == nil {
+		return ""
+	}
+	if *f.colors == converter.ColorsAuto {
+		return "auto"
+	}
+	return strconv.Itoa(*f.colors)
+}
+
+func (f colorsFlag) Set(value string) error {
+	if value == "auto" {
+		*f.colors = converter.ColorsAuto
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("expected a color count or auto, got %q", value)
+	}
+	*f.colors = n
+	return nil
+}
+
+// recolorFlag parses a "from,to" pair of palette files
+type recolorFlag struct {
+	from, to *string
+}
+
+func (f recolorFlag) String() string {
+	if f.from == nil || *f.from == "" {
+		return ""
+	}
+	return *f.from + "," + *f.to
+}
+
+func (f recolorFlag) Set(value string) error {
+	from, to, ok := strings.Cut(value, ",")
+	if !ok || from == "" || to == "" {
+		return fmt.Errorf("expected from,to palette files, got %q", value)
+	}
+	*f.from, *f.to = from, to
+	return nil
+}
+
+// duotoneFlag parses a "shadow,highlight" pair of hex colors
+type duotoneFlag struct {
+	shadow, highlight *string
+}
+
+func (f duotoneFlag) String() string {
+	if f.shadow == nil || *f.shadow == "" {
+		return ""
+	}
+	return *f.shadow + "," + *f.highlight
+}
+
+func (f duotoneFlag) Set(value string) error {
+	shadow, highlight, ok := strings.Cut(value, ",")
+	if !ok || shadow == "" || highlight == "" {
+		return fmt.Errorf("expected shadow,highlight hex colors, got %q", value)
+	}
+	*f.shadow, *f.highlight = shadow, highlight
+	return nil
+}
+
+// rangeFlag parses a "lo,hi" pair of integers
+type rangeFlag struct {
+	lo, hi *int
+}
+
+func (f rangeFlag) String() string {
+	if f.hi == nil || *f.hi == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d,%d", *f.lo, *f.hi)
+}
+
+func (f rangeFlag) Set(value string) error {
+	l, h, ok := strings.Cut(value, ",")
+	if !ok {
+		return fmt.Errorf("expected lo,hi, got %q", value)
+	}
+	lo, err := strconv.Atoi(strings.TrimSpace(l))
+	if err != nil {
+		return fmt.Errorf("invalid low bound %q", l)
+	}
+	hi, err := strconv.Atoi(strings.TrimSpace(h))
+	if err != nil {
+		return fmt.Errorf("invalid high bound %q", h)
+	}
+	*f.lo, *f.hi = lo, hi
+	return nil
+}
+
+// stagesFlag parses a comma-separated list of stage names
+type stagesFlag struct {
+	order *[]string
+}
+
+func (f stagesFlag) String() string {
+	if f.order == nil {
+		return ""
+	}
+	return strings.Join(*f.order, ",")
+}
+
+func (f stagesFlag) Set(value string) error {
+	var order []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			order = append(order, name)
+		}
+	}
+	*f.order = order
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"pixgrid/converter"
+	"testing"
+)
+
+func TestApplyLayersExplicitFlagWins(t *testing.T) {
+	saved := flag.CommandLine
+	t.Cleanup(func() { flag.CommandLine = saved })
+
+	tests := []struct {
+		name   string
+		args   []string
+		sample string
+		dither string
+		colors int
+	}{
+		{"type only", nil, converter.SampleAverage, converter.DitherFloydSteinberg, 32},
+		{"explicit sample", []string{"-sample", converter.SampleCenter}, converter.SampleCenter, converter.DitherFloydSteinberg, 32},
+		{"explicit dither and colors", []string{"-dither", converter.DitherNone, "-colors", "12"}, converter.SampleAverage, converter.DitherNone, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := converter.DefaultConfig()
+			flag.CommandLine = flag.NewFlagSet("pixgrid", flag.ContinueOnError)
+			flag.StringVar(&config.Sample, "sample", config.Sample, "")
+			flag.StringVar(&config.Dither, "dither", config.Dither, "")
+			flag.Var(colorsFlag{&config.Colors}, "colors", "")
+			if err := flag.CommandLine.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := applyLayers(&config, "photo", "", ""); err != nil {
+				t.Fatal(err)
+			}
+			if config.Sample != tt.sample || config.Dither != tt.dither || config.Colors != tt.colors {
+				t.Errorf("got sample %q, dither %q, %d colors; want %q, %q, %d",
+					config.Sample, config.Dither, config.Colors, tt.sample, tt.dither, tt.colors)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// adminMiddleware only lets requests through that carry the configured admin
+// token as a bearer token. Without a configured token the endpoint is disabled
+func (s *Server) adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			writeJSONError(w, http.StatusNotFound, "Not found", codeNotFound)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, "Unauthorized", codeUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		s.listSessions(w)
+	case "DELETE":
+		s.deleteSession(w, r.URL.Query().Get("id"))
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", codeMethodNotAllowed)
+	}
+}
+
+func (s *Server) listSessions(w http.ResponseWriter) {
+	type sessionInfo struct {
+		ID        string    `json:"id"`
+		CreatedAt time.Time `json:"createdAt"`
+		LastUsed  time.Time `json:"lastUsed"`
+		Width     int       `json:"width"`
+		Height    int       `json:"height"`
+		Bytes     int       `json:"bytes"`
+	}
+
+	s.mu.RLock()
+	sessions := make([]sessionInfo, 0, len(s.sessions))
+	for id, session := range s.sessions {
+		sessions = append(sessions, sessionInfo{
+			ID:        id,
+			CreatedAt: session.CreatedAt,
+			LastUsed:  session.LastUsed,
+			Width:     session.Width,
+			Height:    session.Height,
+			Bytes:     len(session.PNG),
+		})
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": sessions,
+	})
+}
+
+func (s *Server) deleteSession(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	_, exists := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Session not found", codeSessionNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
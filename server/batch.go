@@ -0,0 +1,195 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"path/filepath"
+	"pixgrid/converter"
+	"strings"
+)
+
+// Limits for /api/batch
+const (
+	maxBatchFiles = 50
+	maxBatchSize  = 256 << 20
+)
+
+// handleBatch converts every image of a multipart upload with the params
+// from the query string and streams the results back as a zip. Files are
+// read and converted one at a time, so neither the upload nor the zip is
+// held in memory. Once the zip has started, a failing file is recorded as a
+// <name>.error.txt entry instead of an error response
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", codeMethodNotAllowed)
+		return
+	}
+
+	params, err := paramsFromQuery(r.URL.Query())
+	if err == nil {
+		err = params.normalize(s.defaults)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid parameters: "+err.Error(), codeInvalidParams)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchSize)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to read upload: "+err.Error(), codeUploadFailed)
+		return
+	}
+
+	var zw *zip.Writer
+	names := make(map[string]bool)
+	writeEntry := func(name string, data []byte) error {
+		if zw == nil {
+			w.Header().Set("Content-Type", "application/zip")
+			w.Header().Set("Content-Disposition", "attachment; filename=pixelart.zip")
+			zw = zip.NewWriter(w)
+		}
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	}
+	// fail reports an error as JSON before the zip starts and as an entry after
+	fail := func(name string, status int, message, code string) {
+		if zw == nil {
+			writeJSONError(w, status, message, code)
+			return
+		}
+		writeEntry(name+".error.txt", []byte(message+"\n"))
+	}
+
+	files := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				fail("batch", http.StatusRequestEntityTooLarge, fmt.Sprintf("Batch exceeds %d bytes", maxBatchSize), codeTooLarge)
+			} else {
+				fail("batch", http.StatusBadRequest, "Failed to read upload: "+err.Error(), codeUploadFailed)
+			}
+			break
+		}
+		if part.FileName() == "" {
+			continue
+		}
+
+		files++
+		if files > maxBatchFiles {
+			fail("batch", http.StatusRequestEntityTooLarge, fmt.Sprintf("Batch exceeds %d files", maxBatchFiles), codeTooLarge)
+			break
+		}
+
+		name := batchEntryName(part.FileName(), names)
+		data, err := s.convertBatchPart(r, part, params)
+		if errors.Is(err, errBusy) {
+			w.Header().Set("Retry-After", "1")
+			fail(name, http.StatusServiceUnavailable, "Server busy, try again later", codeBusy)
+			break
+		}
+		if err != nil && r.Context().Err() != nil {
+			fail(name, http.StatusServiceUnavailable, "Conversion timed out", codeTimeout)
+			break
+		}
+		if err != nil {
+			status, code := http.StatusBadRequest, codeConversionFailed
+			switch {
+			case errors.Is(err, errUnsupportedType):
+				status, code = http.StatusUnsupportedMediaType, codeUnsupportedMedia
+			case errors.Is(err, errTooManyPixels):
+				status, code = http.StatusRequestEntityTooLarge, codeTooLarge
+			}
+			fail(name, status, "Failed to convert "+part.FileName()+": "+err.Error(), code)
+			if zw == nil {
+				return
+			}
+			continue
+		}
+
+		if err := writeEntry(name, data); err != nil {
+			// The client is gone or the response broke; nothing left to report to
+			return
+		}
+	}
+
+	if zw == nil {
+		if files == 0 {
+			writeJSONError(w, http.StatusBadRequest, "No images in upload", codeUploadFailed)
+		}
+		return
+	}
+	zw.Close()
+}
+
+// errUnsupportedType is a batch file whose sniffed type isn't in uploadTypes
+var errUnsupportedType = errors.New("unsupported file type")
+
+// convertBatchPart decodes and converts one uploaded file, returning the PNG.
+// Like /api/upload, it checks the sniffed type and the pixel count before
+// running a decoder
+func (s *Server) convertBatchPart(r *http.Request, part io.Reader, params convertParams) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(part, maxUploadSize))
+	if err != nil {
+		return nil, err
+	}
+	format, isHEIF := converter.HEIFFormat(data)
+	if isHEIF && !converter.CanDecodeHEIF(format) {
+		return nil, fmt.Errorf("%w: %w", errUnsupportedType, converter.HEIFError(format))
+	}
+	if contentType := http.DetectContentType(data); !isHEIF && !uploadTypes[contentType] {
+		return nil, fmt.Errorf("%w: %s", errUnsupportedType, contentType)
+	}
+	if err := s.checkPixels(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.process(r.Context(), img, params.config())
+	if err != nil {
+		return nil, err
+	}
+	defer result.Release()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, result.Image); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// batchEntryName turns an uploaded file name into a unique .png entry name,
+// dropping any directories a client may have sent
+func batchEntryName(fileName string, used map[string]bool) string {
+	base := filepath.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	if stem == "" || stem == "." || stem == "/" {
+		stem = "image"
+	}
+
+	name := stem + ".png"
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s_%d.png", stem, i)
+	}
+	used[name] = true
+	return name
+}
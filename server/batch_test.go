@@ -0,0 +1,106 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+// batchFile is one file of a test batch upload
+type batchFile struct {
+	name string
+	data []byte
+}
+
+// batchRequest builds a multipart POST to /api/batch holding files
+func batchRequest(t *testing.T, files ...batchFile) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, file := range files {
+		part, err := mw.CreateFormFile("image", file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(file.data)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", "/api/batch?size=8&scale=1", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestHandleBatchRejectsFirstFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		status int
+		code   string
+	}{
+		{"not an image", []byte("just some text"), http.StatusUnsupportedMediaType, codeUnsupportedMedia},
+		{"too many pixels", testPNG(t, 64, 64), http.StatusRequestEntityTooLarge, codeTooLarge},
+		{"broken png", testPNG(t, 16, 16)[:40], http.StatusBadRequest, codeConversionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(WithMaxPixels(32 * 32))
+			rec := httptest.NewRecorder()
+			s.handleBatch(rec, batchRequest(t, batchFile{"a.png", tt.data}))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if code := errorCode(t, rec); code != tt.code {
+				t.Errorf("code = %q, want %q", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestHandleBatchErrorEntryName(t *testing.T) {
+	s := New(WithMaxPixels(32 * 32))
+	rec := httptest.NewRecorder()
+	s.handleBatch(rec, batchRequest(t,
+		batchFile{"a.png", testPNG(t, 16, 16)},
+		batchFile{"dir/a.jpg", []byte("just some text")},
+		batchFile{"b.png", testPNG(t, 64, 64)},
+	))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	want := []string{"a.png", "a_2.png.error.txt", "b.png.error.txt"}
+	if len(names) != len(want) {
+		t.Fatalf("entries = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("entries = %v, want %v", names, want)
+			break
+		}
+	}
+
+	f, err := zr.Open("b.png.error.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if message, _ := io.ReadAll(f); !bytes.Contains(message, []byte("too many pixels")) {
+		t.Errorf("b.png.error.txt = %q, want the pixel limit", message)
+	}
+}
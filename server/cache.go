@@ -0,0 +1,71 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// resultKey identifies a rendered conversion result within a session
+type resultKey struct {
+	convertParams
+	Format string
+	// PaletteVersion identifies the locked palette the result was rendered
+	// with, 0 when none was used
+	PaletteVersion int
+}
+
+func (k resultKey) sameParams(other resultKey) bool {
+	return k.convertParams == other.convertParams
+}
+
+// resultETag derives a strong ETag from the session and the result key. The
+// conversion is deterministic, so equal keys always produce equal bodies
+func resultETag(sessionID string, key resultKey) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%+v", sessionID, key)))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		candidate = strings.TrimPrefix(candidate, "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cachedResult returns the cached body for key, rendering and storing it on a
+// miss. Requesting different params evicts results cached for the old ones
+func (s *Server) cachedResult(session *Session, key resultKey, render func() ([]byte, error)) ([]byte, error) {
+	s.mu.Lock()
+	if !session.resultParams.sameParams(key) {
+		session.results = nil
+		session.resultParams = key
+	}
+	if body, ok := session.results[key]; ok {
+		s.mu.Unlock()
+		return body, nil
+	}
+	s.mu.Unlock()
+
+	body, err := render()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if session.resultParams.sameParams(key) {
+		if session.results == nil {
+			session.results = make(map[resultKey][]byte)
+		}
+		session.results[key] = body
+	}
+	s.mu.Unlock()
+
+	return body, nil
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"pixgrid/converter"
+	"sort"
+)
+
+// capabilitiesMaxAge is how long clients may cache /api/capabilities. The
+// answer only changes with the build, so it can be cached for a while
+const capabilitiesMaxAge = "public, max-age=3600"
+
+// capabilities lists what this build supports, so clients don't have to
+// hard-code palettes, modes or formats
+type capabilities struct {
+	Palettes    []string `json:"palettes"`
+	Modes       []string `json:"modes"`
+	DitherModes []string `json:"ditherModes"`
+	SampleModes []string `json:"sampleModes"`
+	Formats     []string `json:"formats"`
+	UploadTypes []string `json:"uploadTypes"`
+}
+
+func (s *Server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", codeMethodNotAllowed)
+		return
+	}
+
+	types := make([]string, 0, len(uploadTypes))
+	for contentType := range uploadTypes {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", capabilitiesMaxAge)
+	json.NewEncoder(w).Encode(capabilities{
+		Palettes:    converter.PaletteNames(),
+		Modes:       converter.Modes(),
+		DitherModes: converter.DitherModes(),
+		SampleModes: converter.SampleModes(),
+		Formats:     converter.Formats(),
+		UploadTypes: types,
+	})
+}
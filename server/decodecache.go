@@ -0,0 +1,114 @@
+package server
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"image"
+	"io"
+	"sync"
+)
+
+// decodeCache keeps decoded uploads keyed by the SHA-256 of their bytes, so
+// the same image uploaded to several sessions is decoded once. It is bounded
+// by entry count and total pixels, evicting the least recently used image
+type decodeCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxPixels  int64
+	pixels     int64
+	order      *list.List // front is most recently used
+	entries    map[[sha256.Size]byte]*list.Element
+}
+
+type decodeCacheEntry struct {
+	hash   [sha256.Size]byte
+	img    image.Image
+	pixels int64
+}
+
+func newDecodeCache(maxEntries int, maxPixels int64) *decodeCache {
+	return &decodeCache{
+		maxEntries: maxEntries,
+		maxPixels:  maxPixels,
+		order:      list.New(),
+		entries:    make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// get returns the cached image for hash, marking it as recently used
+func (c *decodeCache) get(hash [sha256.Size]byte) (image.Image, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[hash]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*decodeCacheEntry).img, true
+}
+
+// add caches img under hash, evicting old entries to stay within bounds.
+// Images larger than the whole pixel budget are not cached
+func (c *decodeCache) add(hash [sha256.Size]byte, img image.Image) {
+	pixels := int64(img.Bounds().Dx()) * int64(img.Bounds().Dy())
+	if pixels > c.maxPixels {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[hash]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[hash] = c.order.PushFront(&decodeCacheEntry{hash: hash, img: img, pixels: pixels})
+	c.pixels += pixels
+
+	for c.order.Len() > c.maxEntries || c.pixels > c.maxPixels {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*decodeCacheEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.hash)
+		c.pixels -= entry.pixels
+	}
+}
+
+// sessionImage returns a session's original image, from the decode cache
+// when enabled and falling back to decoding the session's PNG
+func (s *Server) sessionImage(session *Session) (image.Image, error) {
+	if s.decodeCache == nil || session.decoded != nil {
+		return session.Image()
+	}
+	if img, ok := s.decodeCache.get(session.contentHash); ok {
+		return img, nil
+	}
+
+	img, err := session.Image()
+	if err != nil {
+		return nil, err
+	}
+	s.decodeCache.add(session.contentHash, img)
+	return img, nil
+}
+
+// decodeUpload decodes an uploaded image, reusing an earlier decode of the
+// same bytes when the decode cache is enabled
+func (s *Server) decodeUpload(r io.Reader, hash [sha256.Size]byte) (image.Image, error) {
+	if s.decodeCache != nil {
+		if img, ok := s.decodeCache.get(hash); ok {
+			return img, nil
+		}
+	}
+
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	if s.decodeCache != nil {
+		s.decodeCache.add(hash, img)
+	}
+	return img, nil
+}
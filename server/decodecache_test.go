@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"image"
+	"testing"
+)
+
+func sized(w, h int) image.Image {
+	return image.NewNRGBA(image.Rect(0, 0, w, h))
+}
+
+func TestDecodeCache(t *testing.T) {
+	a, b, c := sha256.Sum256([]byte("a")), sha256.Sum256([]byte("b")), sha256.Sum256([]byte("c"))
+	tests := []struct {
+		name       string
+		maxEntries int
+		maxPixels  int64
+		run        func(cache *decodeCache)
+		cached     [][sha256.Size]byte
+		evicted    [][sha256.Size]byte
+	}{
+		{"within bounds", 3, 1000, func(cache *decodeCache) {
+			cache.add(a, sized(10, 10))
+			cache.add(b, sized(10, 10))
+		}, [][sha256.Size]byte{a, b}, nil},
+		{"entry limit evicts the oldest", 2, 1000, func(cache *decodeCache) {
+			cache.add(a, sized(10, 10))
+			cache.add(b, sized(10, 10))
+			cache.add(c, sized(10, 10))
+		}, [][sha256.Size]byte{b, c}, [][sha256.Size]byte{a}},
+		{"get refreshes", 2, 1000, func(cache *decodeCache) {
+			cache.add(a, sized(10, 10))
+			cache.add(b, sized(10, 10))
+			cache.get(a)
+			cache.add(c, sized(10, 10))
+		}, [][sha256.Size]byte{a, c}, [][sha256.Size]byte{b}},
+		{"pixel limit", 10, 250, func(cache *decodeCache) {
+			cache.add(a, sized(10, 10))
+			cache.add(b, sized(10, 10))
+			cache.add(c, sized(10, 10))
+		}, [][sha256.Size]byte{b, c}, [][sha256.Size]byte{a}},
+		{"too large to cache", 10, 250, func(cache *decodeCache) {
+			cache.add(a, sized(10, 10))
+			cache.add(b, sized(20, 20))
+		}, [][sha256.Size]byte{a}, [][sha256.Size]byte{b}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newDecodeCache(tt.maxEntries, tt.maxPixels)
+			tt.run(cache)
+			for _, hash := range tt.cached {
+				if _, ok := cache.get(hash); !ok {
+					t.Errorf("%x was evicted", hash[:4])
+				}
+			}
+			for _, hash := range tt.evicted {
+				if _, ok := cache.get(hash); ok {
+					t.Errorf("%x is still cached", hash[:4])
+				}
+			}
+			if cache.pixels > tt.maxPixels {
+				t.Errorf("cache holds %d pixels, over the %d limit", cache.pixels, tt.maxPixels)
+			}
+		})
+	}
+}
+
+func TestDecodeUploadUsesCache(t *testing.T) {
+	data := testPNG(t, 16, 16)
+	hash := sha256.Sum256(data)
+
+	s := New(WithDecodeCache(4, 1<<20))
+	first, err := s.decodeUpload(bytes.NewReader(data), hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A cache hit must not read the body at all
+	second, err := s.decodeUpload(bytes.NewReader(nil), hash)
+	if err != nil {
+		t.Fatalf("second decode: %v", err)
+	}
+	if first != second {
+		t.Error("second decode of the same bytes didn't come from the cache")
+	}
+
+	uncached := New()
+	if _, err := uncached.decodeUpload(bytes.NewReader(nil), hash); err == nil {
+		t.Error("decoding without a cache accepted an empty body")
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Machine-readable error codes returned in JSON error bodies
+const (
+	codeMethodNotAllowed    = "METHOD_NOT_ALLOWED"
+	codeInvalidRequest      = "INVALID_REQUEST"
+	codeInvalidParams       = "INVALID_PARAMS"
+	codeTooLarge            = "REQUEST_TOO_LARGE"
+	codeUploadFailed        = "UPLOAD_FAILED"
+	codeDecodeFailed        = "DECODE_FAILED"
+	codeUnsupportedMedia    = "UNSUPPORTED_MEDIA_TYPE"
+	codeSessionNotFound     = "SESSION_NOT_FOUND"
+	codeIdempotencyConflict = "IDEMPOTENCY_CONFLICT"
+	codeConversionFailed    = "CONVERSION_FAILED"
+	codeTimeout             = "TIMEOUT"
+	codeBusy                = "SERVER_BUSY"
+	codeUnauthorized        = "UNAUTHORIZED"
+	codeNotFound            = "NOT_FOUND"
+	codeInternal            = "INTERNAL_ERROR"
+)
+
+// writeJSONError sends {"error":{"message":...,"code":...}} with the given
+// status
+func writeJSONError(w http.ResponseWriter, status int, message, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{
+			"message": message,
+			"code":    code,
+		},
+	})
+}
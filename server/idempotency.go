@@ -0,0 +1,62 @@
+package server
+
+import (
+	"errors"
+	"time"
+)
+
+// idempotencyTTL is how long an Idempotency-Key maps to the session it created
+const idempotencyTTL = 10 * time.Minute
+
+var errIdempotencyMismatch = errors.New("idempotency key was already used with a different image")
+
+type idempotencyEntry struct {
+	sessionID string
+	imageHash [32]byte
+	expires   time.Time
+}
+
+// idempotentSession returns the session previously created for key, if the
+// key is still fresh and was used with the same image bytes
+func (s *Server) idempotentSession(key string, hash [32]byte) (string, *Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.idempotency[key]
+	if !ok || time.Now().After(entry.expires) {
+		return "", nil, nil
+	}
+	if entry.imageHash != hash {
+		return "", nil, errIdempotencyMismatch
+	}
+
+	session, ok := s.sessions[entry.sessionID]
+	if !ok {
+		return "", nil, nil
+	}
+	return entry.sessionID, session, nil
+}
+
+// storeSession adds a new session, recording it under key when one was sent.
+// If a concurrent retry with the same key won the race, its session is
+// returned instead and the new one is discarded
+func (s *Server) storeSession(key string, hash [32]byte, sessionID string, session *Session) (string, *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key != "" {
+		if entry, ok := s.idempotency[key]; ok && time.Now().Before(entry.expires) && entry.imageHash == hash {
+			if existing, ok := s.sessions[entry.sessionID]; ok {
+				return entry.sessionID, existing
+			}
+		}
+		s.idempotency[key] = idempotencyEntry{
+			sessionID: sessionID,
+			imageHash: hash,
+			expires:   time.Now().Add(idempotencyTTL),
+		}
+	}
+
+	s.sessions[sessionID] = session
+	return sessionID, session
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"image"
+	"net/http"
+	"pixgrid/converter"
+	"time"
+)
+
+// maxQueueWait is how long a conversion waits for a free slot under
+// WithMaxConcurrency before the request is rejected as busy
+const maxQueueWait = 5 * time.Second
+
+// errBusy means every conversion slot stayed taken for maxQueueWait
+var errBusy = errors.New("too many conversions in progress")
+
+// process runs a conversion once one of the WithMaxConcurrency slots is
+// free, queueing for up to maxQueueWait or until ctx is done. A stage cut
+// short by ctx keeps running after process returns, and keeps its slot until
+// it finishes
+func (s *Server) process(ctx context.Context, img image.Image, config converter.Config) (*converter.Result, error) {
+	if s.slots == nil {
+		return converter.ProcessContext(ctx, img, config)
+	}
+
+	timer := time.NewTimer(maxQueueWait)
+	defer timer.Stop()
+
+	select {
+	case s.slots <- struct{}{}:
+	case <-timer.C:
+		return nil, errBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	result, done, err := converter.ProcessContextDone(ctx, img, config)
+	select {
+	case <-done:
+		<-s.slots
+	default:
+		go func() {
+			<-done
+			<-s.slots
+		}()
+	}
+	return result, err
+}
+
+// writeBusy rejects a request that found no free conversion slot
+func writeBusy(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	writeJSONError(w, http.StatusServiceUnavailable, "Server busy, try again later", codeBusy)
+}
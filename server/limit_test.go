@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"pixgrid/converter"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testImage() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+	return img
+}
+
+func TestProcessConcurrencyLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		// want is the most conversions that may run at once; 0 means the
+		// limit is off and all of them can
+		want int32
+	}{
+		{"one", 1, 1},
+		{"two", 2, 2},
+		{"unlimited", 0, 0},
+	}
+
+	const requests = 6
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(WithMaxConcurrency(tt.limit))
+
+			var running, peak atomic.Int32
+			config := converter.Config{PixelSize: 8, Scale: 1}
+			config.Stages.Downscaler = converter.StageFunc(func(img image.Image) image.Image {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				running.Add(-1)
+				return img
+			})
+
+			var wg sync.WaitGroup
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					result, err := s.process(context.Background(), testImage(), config)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					result.Release()
+				}()
+			}
+			wg.Wait()
+
+			if tt.want > 0 && peak.Load() > tt.want {
+				t.Errorf("%d conversions ran at once, want at most %d", peak.Load(), tt.want)
+			}
+			if tt.want == 0 && peak.Load() < 2 {
+				t.Errorf("only %d conversion ran at once without a limit", peak.Load())
+			}
+		})
+	}
+}
+
+func TestProcessQueueCanceled(t *testing.T) {
+	s := New(WithMaxConcurrency(1))
+	s.slots <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.process(ctx, testImage(), converter.Config{PixelSize: 8, Scale: 1}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v while queued", err, context.DeadlineExceeded)
+	}
+
+	<-s.slots
+	result, err := s.process(context.Background(), testImage(), converter.Config{PixelSize: 8, Scale: 1})
+	if err != nil {
+		t.Fatalf("process after the slot freed: %v", err)
+	}
+	result.Release()
+}
+
+func TestProcessHoldsSlotForAbandonedStage(t *testing.T) {
+	s := New(WithMaxConcurrency(1))
+	release := make(chan struct{})
+	config := converter.Config{PixelSize: 8, Scale: 1}
+	config.Stages.Downscaler = converter.StageFunc(func(img image.Image) image.Image {
+		<-release
+		return img
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.process(ctx, testImage(), config); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if len(s.slots) != 1 {
+		t.Error("slot freed while the timed out stage was still running")
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for len(s.slots) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("slot not freed after the stage finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWriteBusy(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeBusy(rec)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if code := errorCode(t, rec); code != codeBusy {
+		t.Errorf("code = %q, want %q", code, codeBusy)
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+)
+
+// defaultDownloadType is sent when the Accept header names none of
+// downloadTypes
+const defaultDownloadType = "image/png"
+
+// downloadTypes maps the media types /api/download can encode to their file
+// extension
+var downloadTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+}
+
+// negotiateDownloadType picks the media type of downloadTypes the Accept
+// header prefers, by q-value and then by order. Wildcards and types we
+// can't encode get defaultDownloadType
+func negotiateDownloadType(accept string) string {
+	best, bestQ := defaultDownloadType, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "q" {
+				if v, err := strconv.ParseFloat(value, 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if _, ok := downloadTypes[mediaType]; !ok {
+			if mediaType != "image/*" && mediaType != "*/*" {
+				continue
+			}
+			mediaType = defaultDownloadType
+		}
+		if q > bestQ {
+			best, bestQ = mediaType, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateDownloadType(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "image/png"},
+		{"image/jpeg", "image/jpeg"},
+		{"IMAGE/GIF", "image/gif"},
+		{"image/webp, image/jpeg", "image/jpeg"},
+		{"image/png;q=0.5, image/jpeg;q=0.9", "image/jpeg"},
+		{"image/gif, image/jpeg", "image/gif"},
+		{"image/jpeg;q=0.1, */*;q=0.8", "image/png"},
+		{"image/*", "image/png"},
+		{"text/html, application/json", "image/png"},
+		{"image/jpeg; q=bogus", "image/jpeg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			if got := negotiateDownloadType(tt.accept); got != tt.want {
+				t.Errorf("negotiateDownloadType(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleDownloadAccept(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+		magic  []byte
+	}{
+		{"", "image/png", []byte("\x89PNG")},
+		{"image/jpeg", "image/jpeg", []byte("\xff\xd8\xff")},
+		{"image/gif", "image/gif", []byte("GIF8")},
+	}
+
+	s := New()
+	sessionID := uploadSession(t, s)
+	etags := make(map[string]bool)
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/download?size=16&scale=2&sessionId="+sessionID, nil)
+			r.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			s.handleDownload(rec, r)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.want {
+				t.Errorf("Content-Type = %q, want %q", got, tt.want)
+			}
+			if !bytes.HasPrefix(rec.Body.Bytes(), tt.magic) {
+				t.Errorf("body doesn't start with the %s signature", tt.want)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("Vary = %q, want Accept", got)
+			}
+
+			// Each format has its own ETag, which revalidates
+			etag := rec.Header().Get("ETag")
+			if etags[etag] {
+				t.Errorf("ETag %s is shared with another format", etag)
+			}
+			etags[etag] = true
+			r.Header.Set("If-None-Match", etag)
+			rec = httptest.NewRecorder()
+			s.handleDownload(rec, r)
+			if rec.Code != http.StatusNotModified {
+				t.Errorf("revalidation status = %d, want %d", rec.Code, http.StatusNotModified)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import "time"
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithRequestTimeout limits how long a single conversion request may run
+// before the server gives up with 503. 0 means no limit
+func WithRequestTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.requestTimeout = d
+	}
+}
+
+// WithDecodedImages keeps each session's decoded image in memory alongside
+// the PNG. This skips a decode per conversion at the cost of roughly
+// width*height*4 bytes per session
+func WithDecodedImages(keep bool) Option {
+	return func(s *Server) {
+		s.keepDecoded = keep
+	}
+}
+
+// WithAdminToken enables the /api/sessions admin endpoint, guarded by the
+// given bearer token
+func WithAdminToken(token string) Option {
+	return func(s *Server) {
+		s.adminToken = token
+	}
+}
+
+// WithTLS serves HTTPS (and HTTP/2) using the given certificate and key
+// files instead of plain HTTP
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *Server) {
+		s.tlsCertFile = certFile
+		s.tlsKeyFile = keyFile
+	}
+}
+
+// WithMultipartMemory sets how many bytes of an upload are buffered in memory
+// before the rest is written to a temp file
+func WithMultipartMemory(n int64) Option {
+	return func(s *Server) {
+		s.multipartMemory = n
+	}
+}
+
+// WithTempDir sets the directory for uploads that exceed the multipart memory
+// threshold. Empty uses the system temp dir
+func WithTempDir(dir string) Option {
+	return func(s *Server) {
+		s.tempDir = dir
+	}
+}
+
+// WithMaxJSONBody caps the request body of the session-based JSON endpoints;
+// larger bodies are rejected with 413
+func WithMaxJSONBody(n int64) Option {
+	return func(s *Server) {
+		s.maxJSONBody = n
+	}
+}
+
+// WithMaxPixels caps the width times height of uploaded images, checked from
+// the image header before decoding. 0 means no limit
+func WithMaxPixels(n int64) Option {
+	return func(s *Server) {
+		s.maxPixels = n
+	}
+}
+
+// WithDefaults sets the size, scale and colors used when a conversion request
+// omits them or sends 0. The built-in defaults are 64, 8 and 0 (no
+// quantization)
+func WithDefaults(size, scale, colors int) Option {
+	return func(s *Server) {
+		s.defaults.Size = size
+		s.defaults.Scale = scale
+		s.defaults.Colors = colors
+	}
+}
+
+// WithMaxConcurrency caps how many conversions run at once across all
+// clients. Further requests queue briefly and are then rejected with 503.
+// 0 means no limit
+func WithMaxConcurrency(n int) Option {
+	return func(s *Server) {
+		s.slots = nil
+		if n > 0 {
+			s.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithDecodeCache shares decoded uploads between sessions, keyed by the
+// SHA-256 of the uploaded bytes, so repeated uploads of the same image are
+// decoded once. At most maxEntries images totalling maxPixels are kept, least
+// recently used first out. A maxEntries of 0 disables the cache
+func WithDecodeCache(maxEntries int, maxPixels int64) Option {
+	return func(s *Server) {
+		s.decodeCache = nil
+		if maxEntries > 0 && maxPixels > 0 {
+			s.decodeCache = newDecodeCache(maxEntries, maxPixels)
+		}
+	}
+}
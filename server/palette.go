@@ -0,0 +1,71 @@
+package server
+
+import (
+	"fmt"
+	"image/color"
+	"pixgrid/converter"
+)
+
+// sessionConfig returns the converter config for params, quantizing to the
+// session's locked palette when params ask for it, along with that palette's
+// version
+func (s *Server) sessionConfig(session *Session, params convertParams, resetPalette bool) (converter.Config, int, error) {
+	config := params.config()
+	if !params.LockPalette || params.Colors == 0 {
+		return config, 0, nil
+	}
+
+	palette, version, err := s.lockedPalette(session, params, resetPalette)
+	if err != nil {
+		return config, 0, err
+	}
+	config.Palette = palette
+	return config, version, nil
+}
+
+// lockedPalette returns the palette locked for the session, computing it with
+// median cut from the current params the first time and after a reset. The
+// returned version changes with every new palette so cached results and
+// ETags from an older one aren't reused
+func (s *Server) lockedPalette(session *Session, params convertParams, reset bool) (color.Palette, int, error) {
+	s.mu.Lock()
+	if reset && session.palette != nil {
+		session.palette = nil
+		session.results = nil
+	}
+	palette, version := session.palette, session.paletteVersion
+	s.mu.Unlock()
+
+	if palette != nil {
+		return palette, version, nil
+	}
+
+	img, err := s.sessionImage(session)
+	if err != nil {
+		return nil, 0, err
+	}
+	small := converter.Downscale(img, params.Size)
+	colors := params.Colors
+	if colors == converter.ColorsAuto {
+		colors = converter.SuggestColorCount(small)
+	}
+	palette = converter.MedianCut(small, colors)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session.palette == nil {
+		session.palette = palette
+		session.paletteVersion++
+	}
+	return session.palette, session.paletteVersion, nil
+}
+
+// paletteHex formats a palette as "#rrggbb" strings for JSON responses
+func paletteHex(palette color.Palette) []string {
+	colors := make([]string, len(palette))
+	for i, c := range palette {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		colors[i] = fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+	}
+	return colors
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"pixgrid/converter"
+	"strconv"
+)
+
+// convertParams are the conversion options shared by every endpoint that
+// renders an image
+type convertParams struct {
+	Size   int    `json:"size"`
+	Scale  int    `json:"scale"`
+	Colors int    `json:"colors"`
+	Mode   string `json:"mode"`
+	// LockPalette quantizes to the session's locked palette, so the colors
+	// don't shift as other params change
+	LockPalette bool `json:"lockPalette"`
+}
+
+// normalize fills omitted or zero fields from defaults and validates the rest
+func (p *convertParams) normalize(defaults convertParams) error {
+	if p.Size <= 0 {
+		p.Size = defaults.Size
+	}
+	if p.Scale <= 0 {
+		p.Scale = defaults.Scale
+	}
+	if p.Colors == 0 {
+		p.Colors = defaults.Colors
+	}
+	if p.Colors < converter.ColorsAuto {
+		return fmt.Errorf("colors must be %d for auto or a positive count, got %d", converter.ColorsAuto, p.Colors)
+	}
+	if p.Mode == "" {
+		p.Mode = converter.ModePixelArt
+	}
+	return converter.CheckEnum("mode", p.Mode, converter.Modes())
+}
+
+// displayScale is the integer factor between the pixel grid and the rendered
+// result. Mosaic output is already at full resolution
+func (p convertParams) displayScale() int {
+	if p.Mode == converter.ModeMosaic {
+		return 1
+	}
+	return p.Scale
+}
+
+func (p convertParams) config() converter.Config {
+	return converter.Config{
+		PixelSize: p.Size,
+		Scale:     p.Scale,
+		Colors:    p.Colors,
+		Mode:      p.Mode,
+	}
+}
+
+// paramsFromQuery reads convertParams from a query string, for GET requests.
+// Omitted fields are left zero for normalize to default
+func paramsFromQuery(query url.Values) (convertParams, error) {
+	p := convertParams{Mode: query.Get("mode")}
+
+	ints := []struct {
+		name  string
+		field *int
+	}{{"size", &p.Size}, {"scale", &p.Scale}, {"colors", &p.Colors}}
+	for _, f := range ints {
+		name, field := f.name, f.field
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		if field == &p.Colors && value == "auto" {
+			value = strconv.Itoa(converter.ColorsAuto)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return p, fmt.Errorf("%s must be an integer, got %q", name, value)
+		}
+		*field = n
+	}
+
+	if value := query.Get("lockPalette"); value != "" {
+		lock, err := strconv.ParseBool(value)
+		if err != nil {
+			return p, fmt.Errorf("lockPalette must be a boolean, got %q", value)
+		}
+		p.LockPalette = lock
+	}
+
+	return p, nil
+}
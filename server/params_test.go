@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"pixgrid/converter"
+	"testing"
+)
+
+func TestConvertParamsNormalize(t *testing.T) {
+	defaults := convertParams{Size: 32, Scale: 4, Colors: 16}
+	tests := []struct {
+		name    string
+		params  convertParams
+		want    convertParams
+		wantErr bool
+	}{
+		{"all omitted", convertParams{}, convertParams{Size: 32, Scale: 4, Colors: 16, Mode: converter.ModePixelArt}, false},
+		{"explicit", convertParams{Size: 10, Scale: 2, Colors: 3, Mode: converter.ModeMosaic}, convertParams{Size: 10, Scale: 2, Colors: 3, Mode: converter.ModeMosaic}, false},
+		{"negative size", convertParams{Size: -5}, convertParams{Size: 32, Scale: 4, Colors: 16, Mode: converter.ModePixelArt}, false},
+		{"auto colors", convertParams{Colors: converter.ColorsAuto}, convertParams{Size: 32, Scale: 4, Colors: converter.ColorsAuto, Mode: converter.ModePixelArt}, false},
+		{"bad colors", convertParams{Colors: -7}, convertParams{}, true},
+		{"bad mode", convertParams{Mode: "oil"}, convertParams{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.params
+			err := p.normalize(defaults)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalize() = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && p != tt.want {
+				t.Errorf("got %+v, want %+v", p, tt.want)
+			}
+		})
+	}
+}
+
+func TestParamsFromQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    convertParams
+		wantErr bool
+	}{
+		{"", convertParams{}, false},
+		{"size=16&scale=3&colors=8&mode=mosaic", convertParams{Size: 16, Scale: 3, Colors: 8, Mode: "mosaic"}, false},
+		{"colors=auto&lockPalette=true", convertParams{Colors: converter.ColorsAuto, LockPalette: true}, false},
+		{"size=big", convertParams{}, true},
+		{"lockPalette=maybe", convertParams{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			p, err := paramsFromQuery(query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("paramsFromQuery() = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && p != tt.want {
+				t.Errorf("got %+v, want %+v", p, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	s := New(WithDefaults(16, 3, 0))
+	dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(testPNG(t, 64, 64))
+
+	rec := httptest.NewRecorder()
+	s.handleConvertInline(rec, inlineRequest(t, dataURL, ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Width, Height int
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Width != 48 || body.Height != 48 {
+		t.Errorf("got %dx%d, want the 16px grid at scale 3", body.Width, body.Height)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"net/http"
+	"pixgrid/converter"
+	"time"
+)
+
+// maxRecolorPalette is the most colors a client-supplied palette may have
+const maxRecolorPalette = 256
+
+// handleRecolor converts the session image quantized to a palette sent by
+// the client, for editing an extracted palette and previewing the result.
+// The response has the same shape as /api/convert
+func (s *Server) handleRecolor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", codeMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		SessionID    string   `json:"sessionId"`
+		Palette      []string `json:"palette"`
+		IncludeSmall bool     `json:"includeSmall"`
+		convertParams
+	}
+
+	if !decodeJSONBody(w, r, s.maxJSONBody, &req) {
+		return
+	}
+
+	s.mu.RLock()
+	session, exists := s.sessions[req.SessionID]
+	s.mu.RUnlock()
+
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Session not found", codeSessionNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	session.LastUsed = time.Now()
+	s.mu.Unlock()
+
+	if err := req.normalize(s.defaults); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid parameters: "+err.Error(), codeInvalidParams)
+		return
+	}
+
+	palette, err := parsePalette(req.Palette)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid palette: "+err.Error(), codeInvalidParams)
+		return
+	}
+
+	img, err := s.sessionImage(session)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to decode image: "+err.Error(), codeDecodeFailed)
+		return
+	}
+
+	config := req.config()
+	config.Palette = palette
+	result, err := s.process(r.Context(), img, config)
+	if errors.Is(err, errBusy) {
+		writeBusy(w)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeJSONError(w, http.StatusServiceUnavailable, "Conversion timed out", codeTimeout)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to convert image: "+err.Error(), codeConversionFailed)
+		return
+	}
+	defer result.Release()
+
+	body, err := convertResponse(result, req.displayScale(), req.IncludeSmall && req.Mode == converter.ModePixelArt)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to encode result", codeInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// parsePalette parses 1 to maxRecolorPalette hex colors
+func parsePalette(hex []string) (color.Palette, error) {
+	if len(hex) < 1 || len(hex) > maxRecolorPalette {
+		return nil, fmt.Errorf("need 1 to %d colors, got %d", maxRecolorPalette, len(hex))
+	}
+
+	palette := make(color.Palette, len(hex))
+	for i, h := range hex {
+		c, err := converter.ParseHexColor(h)
+		if err != nil {
+			return nil, fmt.Errorf("color %d: %w", i, err)
+		}
+		palette[i] = c
+	}
+	return palette, nil
+}
@@ -2,35 +2,86 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	"image/png"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"pixgrid/converter"
+	"runtime/debug"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
-type Session struct {
-	Image     image.Image
-	CreatedAt time.Time
-	LastUsed  time.Time
+const maxUploadSize = 32 << 20 // 32MB max
+
+// defaultMaxJSONBody caps the body of the JSON endpoints that take a session
+// instead of an image
+const defaultMaxJSONBody = 1 << 20
+
+// defaultMaxPixels caps the dimensions of uploaded images, about 256MB once
+// decoded
+const defaultMaxPixels = 64 << 20
+
+// errTooManyPixels is returned for images whose header declares more pixels
+// than the server accepts
+var errTooManyPixels = errors.New("image has too many pixels")
+
+// maxPreviewSize bounds the longer side of the upload preview
+const maxPreviewSize = 512
+
+// uploadTypes are the sniffed content types accepted by /api/upload, matching
+// the registered decoders
+var uploadTypes = map[string]bool{
+	"image/png":    true,
+	"image/jpeg":   true,
+	"image/gif":    true,
+	"image/webp":   true,
+	"image/x-icon": true,
 }
 
 type Server struct {
-	sessions map[string]*Session
-	mu       sync.RWMutex
+	sessions    map[string]*Session
+	idempotency map[string]idempotencyEntry
+	mu          sync.RWMutex
+
+	requestTimeout  time.Duration
+	keepDecoded     bool
+	multipartMemory int64
+	maxJSONBody     int64
+	maxPixels       int64
+	defaults        convertParams
+	slots           chan struct{}
+	decodeCache     *decodeCache
+	tempDir         string
+	adminToken      string
+	tlsCertFile     string
+	tlsKeyFile      string
 }
 
-func New() *Server {
+func New(opts ...Option) *Server {
 	s := &Server{
-		sessions: make(map[string]*Session),
+		sessions:        make(map[string]*Session),
+		idempotency:     make(map[string]idempotencyEntry),
+		requestTimeout:  30 * time.Second,
+		multipartMemory: defaultMultipartMemory,
+		maxJSONBody:     defaultMaxJSONBody,
+		maxPixels:       defaultMaxPixels,
+		defaults:        convertParams{Size: 64, Scale: 8},
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 	go s.cleanupLoop()
 	return s
@@ -46,6 +97,11 @@ func (s *Server) cleanupLoop() {
 				delete(s.sessions, id)
 			}
 		}
+		for key, entry := range s.idempotency {
+			if now.After(entry.expires) {
+				delete(s.idempotency, key)
+			}
+		}
 		s.mu.Unlock()
 	}
 }
@@ -61,8 +117,9 @@ func generateSessionID() (string, error) {
 func (s *Server) corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-None-Match, Idempotency-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -73,53 +130,200 @@ func (s *Server) corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// recoverMiddleware turns a panic in next, such as a decoder bug tripped by
+// a malformed image, into a logged stack trace and a 500 response
+func (s *Server) recoverMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// ErrAbortHandler is how handlers abort a response on purpose
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			slog.Error("Handler panicked", "method", r.Method, "path", r.URL.Path, "panic", v, "stack", string(debug.Stack()))
+			writeJSONError(w, http.StatusInternalServerError, "Internal server error", codeInternal)
+		}()
+		next(w, r)
+	}
+}
+
+// timeoutMiddleware bounds the request context so a slow conversion is
+// abandoned once the configured deadline passes
+func (s *Server) timeoutMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.requestTimeout <= 0 {
+			next(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), s.requestTimeout)
+		defer cancel()
+		next(w, r.WithContext(ctx))
+	}
+}
+
 func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", codeMethodNotAllowed)
 		return
 	}
 
-	r.ParseMultipartForm(32 << 20) // 32MB max
-
-	file, _, err := r.FormFile("image")
+	upload, err := s.readUploadFile(w, r, "image")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload exceeds %d bytes", tooLarge.Limit), codeTooLarge)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Failed to read image: "+err.Error(), http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Failed to read image: "+err.Error(), codeUploadFailed)
+		return
+	}
+	defer upload.Close()
+
+	// Reject anything that isn't an image before running a decoder on it.
+	// DetectContentType only looks at the first 512 bytes
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(upload, head)
+	format, isHEIF := converter.HEIFFormat(head[:n])
+	if isHEIF && !converter.CanDecodeHEIF(format) {
+		writeJSONError(w, http.StatusUnsupportedMediaType, converter.HEIFError(format).Error(), codeUnsupportedMedia)
+		return
+	}
+	// DetectContentType doesn't know HEIF, so a decodable one skips the check
+	if contentType := http.DetectContentType(head[:n]); !isHEIF && !uploadTypes[contentType] {
+		writeJSONError(w, http.StatusUnsupportedMediaType, "Unsupported file type: "+contentType, codeUnsupportedMedia)
 		return
 	}
-	defer file.Close()
 
-	img, _, err := image.Decode(file)
-	if err != nil {
-		http.Error(w, "Failed to decode image: "+err.Error(), http.StatusBadRequest)
+	var hash [sha256.Size]byte
+	digest := sha256.New()
+	digest.Write(head[:n])
+	if _, err := io.Copy(digest, upload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to read image: "+err.Error(), codeUploadFailed)
 		return
 	}
+	digest.Sum(hash[:0])
+
+	// A retried upload with the same key and bytes reuses its session
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey != "" {
+		sessionID, session, err := s.idempotentSession(idempotencyKey, hash)
+		if err != nil {
+			writeJSONError(w, http.StatusConflict, err.Error(), codeIdempotencyConflict)
+			return
+		}
+		if session != nil {
+			writeUploadResponse(w, sessionID, session)
+			return
+		}
+	}
 
-	sessionID, err := generateSessionID()
+	// Read the dimensions from the header first, so a small file declaring
+	// a huge image is rejected before the decoder allocates its pixels
+	if _, err := upload.Seek(0, io.SeekStart); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read image", codeInternal)
+		return
+	}
+	if err := s.checkPixels(upload); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if _, err := upload.Seek(0, io.SeekStart); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read image", codeInternal)
+		return
+	}
+	img, err := s.decodeUpload(upload, hash)
 	if err != nil {
-		http.Error(w, "Failed to generate session ID", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusBadRequest, "Failed to decode image: "+err.Error(), codeDecodeFailed)
 		return
 	}
 
-	s.mu.Lock()
-	s.sessions[sessionID] = &Session{
-		Image:     img,
-		CreatedAt: time.Now(),
-		LastUsed:  time.Now(),
+	sessionID, err := generateSessionID()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to generate session ID", codeInternal)
+		return
 	}
-	s.mu.Unlock()
 
-	// Encode original image as base64 for preview
+	// Keep the original PNG-encoded
 	var buf bytes.Buffer
 	if err := png.Encode(&buf, img); err != nil {
-		http.Error(w, "Failed to encode image", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to encode image", codeInternal)
+		return
+	}
+
+	// The preview is only for display, so it gets a smooth thumbnail rather
+	// than the full image
+	preview, thumb := buf.Bytes(), converter.Thumbnail(img, maxPreviewSize)
+	if thumb != img {
+		var previewBuf bytes.Buffer
+		if err := png.Encode(&previewBuf, thumb); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to encode image", codeInternal)
+			return
+		}
+		preview = previewBuf.Bytes()
+	}
+
+	dominant := converter.DominantColor(img)
+	session := &Session{
+		PNG:           buf.Bytes(),
+		Width:         img.Bounds().Dx(),
+		Height:        img.Bounds().Dy(),
+		Preview:       preview,
+		PreviewWidth:  thumb.Bounds().Dx(),
+		PreviewHeight: thumb.Bounds().Dy(),
+		DominantColor: fmt.Sprintf("#%02x%02x%02x", dominant.R, dominant.G, dominant.B),
+		Hash:          fmt.Sprintf("%016x", converter.PerceptualHash(img)),
+		CreatedAt:     time.Now(),
+		LastUsed:      time.Now(),
+		contentHash:   hash,
+	}
+	if s.keepDecoded {
+		session.decoded = img
+	}
+
+	sessionID, session = s.storeSession(idempotencyKey, hash, sessionID, session)
+
+	writeUploadResponse(w, sessionID, session)
+}
+
+// checkPixels reads the image header from r and rejects images larger than
+// maxPixels with errTooManyPixels
+func (s *Server) checkPixels(r io.Reader) error {
+	if s.maxPixels <= 0 {
+		return nil
+	}
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return err
+	}
+	if pixels := int64(config.Width) * int64(config.Height); pixels > s.maxPixels {
+		return fmt.Errorf("%w: %dx%d is over the limit of %d", errTooManyPixels, config.Width, config.Height, s.maxPixels)
+	}
+	return nil
+}
+
+// writeDecodeError reports a checkPixels failure: 413 for an image that is
+// too large, 400 for one whose header can't be read
+func writeDecodeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errTooManyPixels) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, err.Error(), codeTooLarge)
 		return
 	}
+	writeJSONError(w, http.StatusBadRequest, "Failed to decode image: "+err.Error(), codeDecodeFailed)
+}
 
+func writeUploadResponse(w http.ResponseWriter, sessionID string, session *Session) {
 	response := map[string]interface{}{
-		"sessionId": sessionID,
-		"width":     img.Bounds().Dx(),
-		"height":    img.Bounds().Dy(),
-		"original":  "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+		"sessionId":     sessionID,
+		"width":         session.Width,
+		"height":        session.Height,
+		"original":      "data:image/png;base64," + base64.StdEncoding.EncodeToString(session.Preview),
+		"previewWidth":  session.PreviewWidth,
+		"previewHeight": session.PreviewHeight,
+		"dominantColor": session.DominantColor,
+		"hash":          session.Hash,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -128,19 +332,18 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleConvert(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", codeMethodNotAllowed)
 		return
 	}
 
 	var req struct {
-		SessionID string `json:"sessionId"`
-		Size      int    `json:"size"`
-		Scale     int    `json:"scale"`
-		Colors    int    `json:"colors"`
+		SessionID    string `json:"sessionId"`
+		IncludeSmall bool   `json:"includeSmall"`
+		ResetPalette bool   `json:"resetPalette"`
+		convertParams
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeJSONBody(w, r, s.maxJSONBody, &req) {
 		return
 	}
 
@@ -149,7 +352,7 @@ func (s *Server) handleConvert(w http.ResponseWriter, r *http.Request) {
 	s.mu.RUnlock()
 
 	if !exists {
-		http.Error(w, "Session not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "Session not found", codeSessionNotFound)
 		return
 	}
 
@@ -158,50 +361,145 @@ func (s *Server) handleConvert(w http.ResponseWriter, r *http.Request) {
 	session.LastUsed = time.Now()
 	s.mu.Unlock()
 
-	// Apply defaults
-	if req.Size <= 0 {
-		req.Size = 64
+	if err := req.normalize(s.defaults); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid parameters: "+err.Error(), codeInvalidParams)
+		return
 	}
-	if req.Scale <= 0 {
-		req.Scale = 8
+
+	config, version, err := s.sessionConfig(session, req.convertParams, req.ResetPalette)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to compute palette: "+err.Error(), codeConversionFailed)
+		return
 	}
 
-	// Convert the image
-	result := ConvertImage(session.Image, req.Size, req.Scale, req.Colors)
+	format := "json"
+	if req.IncludeSmall {
+		format = "json+small"
+	}
+	key := resultKey{convertParams: req.convertParams, Format: format, PaletteVersion: version}
+	etag := resultETag(req.SessionID, key)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 
-	// Encode to PNG
-	var buf bytes.Buffer
-	if err := png.Encode(&buf, result); err != nil {
-		http.Error(w, "Failed to encode result", http.StatusInternalServerError)
+	body, err := s.cachedResult(session, key, func() ([]byte, error) {
+		img, err := s.sessionImage(session)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := s.process(r.Context(), img, config)
+		if err != nil {
+			return nil, err
+		}
+		defer result.Release()
+
+		// Only a locked palette is reported
+		if config.Palette == nil {
+			result.Palette = nil
+		}
+		// Mosaic output stays at the original resolution, so there is no
+		// small image to return
+		return convertResponse(result, req.displayScale(), req.IncludeSmall && req.Mode == converter.ModePixelArt)
+	})
+	if errors.Is(err, errBusy) {
+		writeBusy(w)
 		return
 	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeJSONError(w, http.StatusServiceUnavailable, "Conversion timed out", codeTimeout)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to convert image: "+err.Error(), codeConversionFailed)
+		return
+	}
+
+	// Only a body that was actually produced gets a validator; an error
+	// response must not be cached under it
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// convertResponse builds the /api/convert JSON body, adding the small image
+// when includeSmall is set and the palette of the result when there is one.
+// scale and the grid size let clients display the result at exact integer
+// multiples so it stays crisp
+func convertResponse(result *converter.Result, scale int, includeSmall bool) ([]byte, error) {
+	dataURL, err := pngDataURL(result.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	// The small image is the grid itself; the final size can't be divided
+	// back down once padding or rectangular pixels are involved
+	gridWidth, gridHeight := result.FinalWidth/scale, result.FinalHeight/scale
+	if result.Small != nil {
+		gridWidth, gridHeight = result.Small.Bounds().Dx(), result.Small.Bounds().Dy()
+	}
 
 	response := map[string]interface{}{
-		"image":  "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
-		"width":  result.Bounds().Dx(),
-		"height": result.Bounds().Dy(),
+		"image":        dataURL,
+		"width":        result.FinalWidth,
+		"height":       result.FinalHeight,
+		"actualColors": converter.CountColors(result.Image),
+		"scale":        scale,
+		"gridWidth":    gridWidth,
+		"gridHeight":   gridHeight,
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	if includeSmall && result.Small != nil {
+		smallImage, err := pngDataURL(result.Small)
+		if err != nil {
+			return nil, err
+		}
+		response["smallImage"] = smallImage
+		response["smallWidth"] = result.Small.Bounds().Dx()
+		response["smallHeight"] = result.Small.Bounds().Dy()
+	}
+
+	if result.Palette != nil {
+		response["palette"] = paletteHex(result.Palette)
+	}
+
+	return json.Marshal(response)
 }
 
-func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// pngDataURL encodes img as a base64 PNG data URL
+func pngDataURL(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
 	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
 
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		SessionID string `json:"sessionId"`
-		Size      int    `json:"size"`
-		Scale     int    `json:"scale"`
-		Colors    int    `json:"colors"`
+		convertParams
 	}
 
-	body, _ := io.ReadAll(r.Body)
-	if err := json.Unmarshal(body, &req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	// GET takes the params from the query string so downloads can be
+	// plain links
+	switch r.Method {
+	case "GET":
+		params, err := paramsFromQuery(r.URL.Query())
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid parameters: "+err.Error(), codeInvalidParams)
+			return
+		}
+		req.SessionID = r.URL.Query().Get("sessionId")
+		req.convertParams = params
+	case "POST":
+		if !decodeJSONBody(w, r, s.maxJSONBody, &req) {
+			return
+		}
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", codeMethodNotAllowed)
 		return
 	}
 
@@ -210,52 +508,241 @@ func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 	s.mu.RUnlock()
 
 	if !exists {
-		http.Error(w, "Session not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "Session not found", codeSessionNotFound)
+		return
+	}
+
+	if err := req.normalize(s.defaults); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid parameters: "+err.Error(), codeInvalidParams)
+		return
+	}
+
+	config, version, err := s.sessionConfig(session, req.convertParams, false)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to compute palette: "+err.Error(), codeConversionFailed)
+		return
+	}
+
+	// The Accept header picks the encoding, PNG unless it asks otherwise
+	contentType := negotiateDownloadType(r.Header.Get("Accept"))
+	ext := downloadTypes[contentType]
+	w.Header().Add("Vary", "Accept")
+
+	key := resultKey{convertParams: req.convertParams, Format: strings.TrimPrefix(ext, "."), PaletteVersion: version}
+	etag := resultETag(req.SessionID, key)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, err := s.cachedResult(session, key, func() ([]byte, error) {
+		img, err := s.sessionImage(session)
+		if err != nil {
+			return nil, err
+		}
+
+		// Convert the image
+		result, err := s.process(r.Context(), img, config)
+		if err != nil {
+			return nil, err
+		}
+		defer result.Release()
+
+		return converter.EncodeAs(result.Image, ext)
+	})
+	if errors.Is(err, errBusy) {
+		writeBusy(w)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeJSONError(w, http.StatusServiceUnavailable, "Conversion timed out", codeTimeout)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to convert image: "+err.Error(), codeConversionFailed)
+		return
+	}
+
+	// Send as file
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=pixelart"+ext)
+	w.Write(data)
+}
+
+// handleSessionDelete lets a client free its own session once done, instead
+// of waiting for the idle cleanup. The session ID itself is the credential
+func (s *Server) handleSessionDelete(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SessionID string `json:"sessionId"`
+	}
+
+	switch r.Method {
+	case "DELETE":
+		req.SessionID = r.URL.Query().Get("sessionId")
+	case "POST":
+		if !decodeJSONBody(w, r, s.maxJSONBody, &req) {
+			return
+		}
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", codeMethodNotAllowed)
+		return
+	}
+
+	s.deleteSession(w, req.SessionID)
+}
+
+func (s *Server) handleConvertInline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", codeMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Image string `json:"image"`
+		convertParams
+	}
+
+	// Base64 inflates the payload by 4/3, plus some room for the other fields
+	if !decodeJSONBody(w, r, maxUploadSize/3*4+4096, &req) {
 		return
 	}
 
-	// Apply defaults
-	if req.Size <= 0 {
-		req.Size = 64
+	data, err := decodeDataURL(req.Image)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid image data: "+err.Error(), codeInvalidRequest)
+		return
+	}
+
+	if err := s.checkPixels(bytes.NewReader(data)); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to decode image: "+err.Error(), codeDecodeFailed)
+		return
 	}
-	if req.Scale <= 0 {
-		req.Scale = 8
+
+	if err := req.normalize(s.defaults); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid parameters: "+err.Error(), codeInvalidParams)
+		return
 	}
 
 	// Convert the image
-	result := ConvertImage(session.Image, req.Size, req.Scale, req.Colors)
+	result, err := s.process(r.Context(), img, req.config())
+	if errors.Is(err, errBusy) {
+		writeBusy(w)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeJSONError(w, http.StatusServiceUnavailable, "Conversion timed out", codeTimeout)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to convert image: "+err.Error(), codeConversionFailed)
+		return
+	}
+	defer result.Release()
+
+	// Encode to PNG
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, result.Image); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to encode result", codeInternal)
+		return
+	}
 
-	// Encode to PNG and send as file
-	w.Header().Set("Content-Type", "image/png")
-	w.Header().Set("Content-Disposition", "attachment; filename=pixelart.png")
-	png.Encode(w, result)
+	response := map[string]interface{}{
+		"image":  "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+		"width":  result.FinalWidth,
+		"height": result.FinalHeight,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
-// ConvertImage applies the pixel art conversion to an in-memory image
-func ConvertImage(img image.Image, pixelSize, scale, colors int) image.Image {
-	// Downscale
-	smallImg := converter.Downscale(img, pixelSize)
+// decodeJSONBody decodes the request body into v, reading at most limit
+// bytes. On failure it writes the error response, 413 when the body is too
+// large, and returns false
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, limit int64, v any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	err := json.NewDecoder(r.Body).Decode(v)
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit), codeTooLarge)
+		return false
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body", codeInvalidRequest)
+		return false
+	}
+	return true
+}
+
+// decodeDataURL extracts the payload of a base64 "data:image/...;base64," URL
+func decodeDataURL(dataURL string) ([]byte, error) {
+	if !strings.HasPrefix(dataURL, "data:image/") {
+		return nil, fmt.Errorf("expected a data:image/ URL")
+	}
+
+	header, payload, ok := strings.Cut(dataURL, ",")
+	if !ok || !strings.HasSuffix(header, ";base64") {
+		return nil, fmt.Errorf("expected base64-encoded data")
+	}
 
-	// Quantize colors if specified
-	if colors > 0 {
-		smallImg = converter.QuantizeColors(smallImg, colors)
+	if base64.StdEncoding.DecodedLen(len(payload)) > maxUploadSize {
+		return nil, fmt.Errorf("image exceeds %d bytes", maxUploadSize)
 	}
 
-	// Upscale with nearest neighbor
-	return converter.UpscaleNearestNeighbor(smallImg, scale)
+	return base64.StdEncoding.DecodeString(payload)
 }
 
 func (s *Server) SetupRoutes() *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/upload", s.corsMiddleware(s.handleUpload))
-	mux.HandleFunc("/api/convert", s.corsMiddleware(s.handleConvert))
-	mux.HandleFunc("/api/download", s.corsMiddleware(s.handleDownload))
+	// Every handler recovers from panics so one bad request can't take
+	// down the server
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, s.recoverMiddleware(handler))
+	}
+	handle("/api/upload", s.corsMiddleware(s.handleUpload))
+	handle("/api/convert", s.corsMiddleware(s.timeoutMiddleware(s.handleConvert)))
+	handle("/api/download", s.corsMiddleware(s.timeoutMiddleware(s.handleDownload)))
+	handle("/api/recolor", s.corsMiddleware(s.timeoutMiddleware(s.handleRecolor)))
+	handle("/api/sessions", s.corsMiddleware(s.adminMiddleware(s.handleSessions)))
+	handle("/api/session/delete", s.corsMiddleware(s.handleSessionDelete))
+	handle("/api/batch", s.corsMiddleware(s.timeoutMiddleware(s.handleBatch)))
+	handle("/api/convert-inline", s.corsMiddleware(s.timeoutMiddleware(s.handleConvertInline)))
+	handle("/api/capabilities", s.corsMiddleware(s.handleCapabilities))
 	return mux
 }
 
+// Start listens on port and serves until the listener fails, over HTTPS
+// when WithTLS is set
 func (s *Server) Start(port int) error {
-	mux := s.SetupRoutes()
 	addr := ":" + strconv.Itoa(port)
-	fmt.Printf("Server starting on http://localhost%s\n", addr)
-	return http.ListenAndServe(addr, mux)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	scheme := "http"
+	if s.tlsCertFile != "" {
+		scheme = "https"
+	}
+	fmt.Printf("Server starting on %s://localhost%s\n", scheme, addr)
+	return s.Serve(ln)
+}
+
+// Serve serves the routes on ln like Start, closing it on return
+func (s *Server) Serve(ln net.Listener) error {
+	mux := s.SetupRoutes()
+
+	// ServeTLS negotiates HTTP/2 automatically
+	if s.tlsCertFile != "" {
+		return http.ServeTLS(ln, mux, s.tlsCertFile, s.tlsKeyFile)
+	}
+	return http.Serve(ln, mux)
 }
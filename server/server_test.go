@@ -0,0 +1,362 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// inlineRequest builds a POST to /api/convert-inline with a PNG data URL and
+// the extra JSON fields in params
+func inlineRequest(t *testing.T, image, params string) *http.Request {
+	t.Helper()
+	body := `{"image":"` + image + `"` + params + `}`
+	r := httptest.NewRequest("POST", "/api/convert-inline", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestHandleConvertInline(t *testing.T) {
+	dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(testPNG(t, 64, 64))
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		image  string
+		params string
+		ctx    context.Context
+		status int
+		code   string
+	}{
+		{"png", dataURL, `,"size":8,"scale":2`, nil, http.StatusOK, ""},
+		{"not a data URL", "hello", "", nil, http.StatusBadRequest, codeInvalidRequest},
+		{"undecodable", "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("not a png")), "", nil, http.StatusBadRequest, codeDecodeFailed},
+		{"bad params", dataURL, `,"mode":"oil"`, nil, http.StatusBadRequest, codeInvalidParams},
+		{"conversion failed", dataURL, "", canceled, http.StatusInternalServerError, codeConversionFailed},
+	}
+
+	s := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := inlineRequest(t, tt.image, tt.params)
+			if tt.ctx != nil {
+				r = r.WithContext(tt.ctx)
+			}
+			rec := httptest.NewRecorder()
+			s.handleConvertInline(rec, r)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.code != "" {
+				if code := errorCode(t, rec); code != tt.code {
+					t.Errorf("code = %q, want %q", code, tt.code)
+				}
+			}
+		})
+	}
+}
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and its key
+// to dir, returning their paths and a pool trusting the certificate
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "pixgrid test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestServeTLS(t *testing.T) {
+	certFile, keyFile, pool := writeTestCert(t, t.TempDir())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go New(WithTLS(certFile, keyFile)).Serve(ln)
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool},
+		ForceAttemptHTTP2: true,
+	}}
+	t.Cleanup(client.CloseIdleConnections)
+	url := "https://" + ln.Addr().String()
+
+	var upload bytes.Buffer
+	mw := multipart.NewWriter(&upload)
+	part, err := mw.CreateFormFile("image", "image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(testPNG(t, 64, 48))
+	mw.Close()
+	resp, err := client.Post(url+"/api/upload", mw.FormDataContentType(), &upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session struct {
+		SessionID string `json:"sessionId"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&session)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload status = %d, error %v", resp.StatusCode, err)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Errorf("protocol = %s, want HTTP/2", resp.Proto)
+	}
+
+	body := `{"sessionId":"` + session.SessionID + `","size":16,"scale":2}`
+	resp, err = client.Post(url+"/api/convert", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Image string `json:"image"`
+		Width int    `json:"width"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("convert status = %d, error %v", resp.StatusCode, err)
+	}
+	if !strings.HasPrefix(result.Image, "data:image/png;base64,") || result.Width != 32 {
+		t.Errorf("convert returned width %d and image %.30q", result.Width, result.Image)
+	}
+}
+
+func TestWithTLS(t *testing.T) {
+	s := New(WithTLS("cert.pem", "key.pem"))
+	if s.tlsCertFile != "cert.pem" || s.tlsKeyFile != "key.pem" {
+		t.Errorf("got cert %q and key %q", s.tlsCertFile, s.tlsKeyFile)
+	}
+}
+
+func TestJSONBodyLimit(t *testing.T) {
+	padding := `,"pad":"` + strings.Repeat("x", 2048) + `"`
+	tests := []struct {
+		name   string
+		limit  int64
+		body   string
+		status int
+		code   string
+	}{
+		{"within limit", 4096, `{"sessionId":"missing"` + padding + `}`, http.StatusNotFound, codeSessionNotFound},
+		{"over limit", 1024, `{"sessionId":"missing"` + padding + `}`, http.StatusRequestEntityTooLarge, codeTooLarge},
+		{"over default limit", 0, `{"sessionId":"missing","pad":"` + strings.Repeat("x", defaultMaxJSONBody) + `"}`, http.StatusRequestEntityTooLarge, codeTooLarge},
+		{"malformed", 0, `{"sessionId":`, http.StatusBadRequest, codeInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.limit > 0 {
+				opts = append(opts, WithMaxJSONBody(tt.limit))
+			}
+			s := New(opts...)
+			rec := httptest.NewRecorder()
+			s.handleConvert(rec, httptest.NewRequest("POST", "/api/convert", strings.NewReader(tt.body)))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if code := errorCode(t, rec); code != tt.code {
+				t.Errorf("code = %q, want %q", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		status    int
+		wantPanic bool
+	}{
+		{"no panic", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}, http.StatusTeapot, false},
+		{"panic", func(w http.ResponseWriter, r *http.Request) {
+			panic("decoder bug")
+		}, http.StatusInternalServerError, false},
+		{"abort", func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}, 0, true},
+	}
+
+	s := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			defer func() {
+				if v := recover(); (v != nil) != tt.wantPanic {
+					t.Errorf("panic = %v, want panic %v", v, tt.wantPanic)
+				}
+			}()
+			s.recoverMiddleware(tt.handler)(rec, httptest.NewRequest("GET", "/", nil))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusInternalServerError {
+				if code := errorCode(t, rec); code != codeInternal {
+					t.Errorf("code = %q, want %q", code, codeInternal)
+				}
+			}
+		})
+	}
+}
+
+func TestServerSurvivesPanic(t *testing.T) {
+	s := New()
+	mux := s.SetupRoutes()
+	mux.HandleFunc("/api/boom", s.recoverMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	for _, path := range []string{"/api/boom", "/api/capabilities"} {
+		resp, err := ts.Client().Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		resp.Body.Close()
+		if path == "/api/boom" && resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("%s: status = %d, want %d", path, resp.StatusCode, http.StatusInternalServerError)
+		}
+		if path == "/api/capabilities" && resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: status = %d after a panic, want %d", path, resp.StatusCode, http.StatusOK)
+		}
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	tests := []struct {
+		name         string
+		timeout      time.Duration
+		wantDeadline bool
+	}{
+		{"default", 30 * time.Second, true},
+		{"short", time.Millisecond, true},
+		{"zero disables", 0, false},
+		{"negative disables", -time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(WithRequestTimeout(tt.timeout))
+			var hasDeadline bool
+			handler := s.timeoutMiddleware(func(w http.ResponseWriter, r *http.Request) {
+				_, hasDeadline = r.Context().Deadline()
+			})
+			handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			if hasDeadline != tt.wantDeadline {
+				t.Errorf("request has deadline %v, want %v", hasDeadline, tt.wantDeadline)
+			}
+		})
+	}
+}
+
+func TestConvertInlineTimeout(t *testing.T) {
+	s := New(WithRequestTimeout(time.Nanosecond))
+	dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(testPNG(t, 64, 64))
+	rec := httptest.NewRecorder()
+	s.timeoutMiddleware(s.handleConvertInline)(rec, inlineRequest(t, dataURL, ""))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusServiceUnavailable, rec.Body)
+	}
+	if code := errorCode(t, rec); code != codeTimeout {
+		t.Errorf("code = %q, want %q", code, codeTimeout)
+	}
+}
+
+func TestETagOnlyOnSuccess(t *testing.T) {
+	s := New(WithRequestTimeout(time.Nanosecond))
+	sessionID := uploadSession(t, s)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		request func() *http.Request
+	}{
+		{"convert", s.handleConvert, func() *http.Request {
+			body := `{"sessionId":"` + sessionID + `","size":16,"scale":2}`
+			return httptest.NewRequest("POST", "/api/convert", strings.NewReader(body))
+		}},
+		{"download", s.handleDownload, func() *http.Request {
+			return httptest.NewRequest("GET", "/api/download?size=16&scale=2&sessionId="+sessionID, nil)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.timeoutMiddleware(tt.handler)(rec, tt.request())
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusServiceUnavailable, rec.Body)
+			}
+			if etag := rec.Header().Get("ETag"); etag != "" {
+				t.Errorf("failed conversion has ETag %s", etag)
+			}
+
+			rec = httptest.NewRecorder()
+			tt.handler(rec, tt.request())
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			if rec.Header().Get("ETag") == "" {
+				t.Error("successful conversion has no ETag")
+			}
+		})
+	}
+}
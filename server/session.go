@@ -0,0 +1,49 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"image/png"
+	"time"
+)
+
+// Session holds an uploaded image between conversions. The original is kept
+// PNG-encoded, which is several times smaller than the decoded pixels, and is
+// decoded again for each conversion unless the server keeps decoded images
+type Session struct {
+	PNG           []byte
+	Width         int
+	Height        int
+	DominantColor string
+	Hash          string
+	CreatedAt     time.Time
+	LastUsed      time.Time
+
+	// Preview is the PNG thumbnail returned by the upload, the same as PNG
+	// when the image is small enough
+	Preview       []byte
+	PreviewWidth  int
+	PreviewHeight int
+
+	// contentHash is the SHA-256 of the uploaded bytes, keying the shared
+	// decode cache
+	contentHash  [sha256.Size]byte
+	decoded      image.Image
+	results      map[resultKey][]byte
+	resultParams resultKey
+
+	// palette is the locked palette reused by conversions with lockPalette
+	// set, until a request resets it
+	palette        color.Palette
+	paletteVersion int
+}
+
+// Image returns the session's original image
+func (sess *Session) Image() (image.Image, error) {
+	if sess.decoded != nil {
+		return sess.decoded, nil
+	}
+	return png.Decode(bytes.NewReader(sess.PNG))
+}
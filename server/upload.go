@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+)
+
+// defaultMultipartMemory is how much of an upload is buffered in memory before
+// it spills to a temp file
+const defaultMultipartMemory = 32 << 20
+
+// spooledUpload is an uploaded file held in memory up to the server's
+// threshold and in a temp file beyond it. Close removes the temp file
+type spooledUpload struct {
+	io.ReadSeeker
+	file *os.File
+}
+
+func (u *spooledUpload) Close() error {
+	if u.file == nil {
+		return nil
+	}
+	u.file.Close()
+	return os.Remove(u.file.Name())
+}
+
+// readUploadFile streams the multipart form and spools the named file field,
+// skipping the other parts. The body is capped at maxUploadSize; going over
+// it fails with *http.MaxBytesError. The caller must Close the result
+func (s *Server) readUploadFile(w http.ResponseWriter, r *http.Request, field string) (*spooledUpload, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, http.ErrMissingFile
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() != field || part.FileName() == "" {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if _, err := io.CopyN(&buf, part, s.multipartMemory+1); err == io.EOF {
+			return &spooledUpload{ReadSeeker: bytes.NewReader(buf.Bytes())}, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		// Over the threshold: write what was buffered and the rest to disk
+		file, err := os.CreateTemp(s.tempDir, "pixgrid-upload-")
+		if err != nil {
+			return nil, err
+		}
+		upload := &spooledUpload{ReadSeeker: file, file: file}
+		if _, err := io.Copy(file, io.MultiReader(&buf, part)); err != nil {
+			upload.Close()
+			return nil, err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			upload.Close()
+			return nil, err
+		}
+		return upload, nil
+	}
+}
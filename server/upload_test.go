@@ -0,0 +1,249 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testPNG encodes a small gradient as PNG
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 255 / w), uint8(y * 255 / h), 128, 255})
+		}
+	}
+	return encodePNG(t, img)
+}
+
+// encodePNG encodes img as PNG
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// uploadRequest builds a multipart POST to /api/upload with file streamed as
+// the named field
+func uploadRequest(t *testing.T, field string, file io.Reader) *http.Request {
+	pr, pw := io.Pipe()
+	// Unblock the writer when a handler gives up before the end of the body
+	t.Cleanup(func() { pr.Close() })
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile(field, "image.png")
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	r := httptest.NewRequest("POST", "/api/upload", pr)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+// errorCode returns the code of a JSON error response
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding error body %q: %v", rec.Body.String(), err)
+	}
+	return body.Error.Code
+}
+
+func TestHandleUpload(t *testing.T) {
+	tests := []struct {
+		name   string
+		field  string
+		file   func() io.Reader
+		status int
+		code   string
+	}{
+		{"png", "image", func() io.Reader { return bytes.NewReader(testPNG(t, 32, 24)) }, http.StatusOK, ""},
+		{"missing field", "other", func() io.Reader { return bytes.NewReader(testPNG(t, 32, 24)) }, http.StatusBadRequest, codeUploadFailed},
+		{"not an image", "image", func() io.Reader { return strings.NewReader("just some text") }, http.StatusUnsupportedMediaType, codeUnsupportedMedia},
+		{"too large", "image", func() io.Reader {
+			return io.MultiReader(bytes.NewReader(testPNG(t, 8, 8)), io.LimitReader(zeros{}, maxUploadSize))
+		}, http.StatusRequestEntityTooLarge, codeTooLarge},
+	}
+
+	s := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.handleUpload(rec, uploadRequest(t, tt.field, tt.file()))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.code != "" {
+				if code := errorCode(t, rec); code != tt.code {
+					t.Errorf("code = %q, want %q", code, tt.code)
+				}
+			}
+		})
+	}
+}
+
+// zeros is an endless reader of zero bytes
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestHandleUploadDominantColor(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(img.Pix[i:], []byte{0x33, 0x66, 0x99, 0xff})
+	}
+
+	rec := httptest.NewRecorder()
+	New().handleUpload(rec, uploadRequest(t, "image", bytes.NewReader(encodePNG(t, img))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		DominantColor string `json:"dominantColor"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.DominantColor != "#336699" {
+		t.Errorf("dominantColor = %q, want #336699", body.DominantColor)
+	}
+}
+
+// uploadSession uploads a test PNG to s and returns the new session ID
+func uploadSession(t *testing.T, s *Server) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.handleUpload(rec, uploadRequest(t, "image", bytes.NewReader(testPNG(t, 64, 48))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload status = %d: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body.SessionID
+}
+
+func TestHandleUploadIdempotency(t *testing.T) {
+	first, second := testPNG(t, 32, 24), testPNG(t, 24, 32)
+	tests := []struct {
+		name     string
+		key      string
+		retry    []byte
+		status   int
+		sameID   bool
+		wantCode string
+	}{
+		{"retry with the same bytes", "key-1", first, http.StatusOK, true, ""},
+		{"retry with other bytes", "key-2", second, http.StatusConflict, false, codeIdempotencyConflict},
+		{"no key", "", first, http.StatusOK, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New()
+			upload := func(data []byte) *httptest.ResponseRecorder {
+				r := uploadRequest(t, "image", bytes.NewReader(data))
+				if tt.key != "" {
+					r.Header.Set("Idempotency-Key", tt.key)
+				}
+				rec := httptest.NewRecorder()
+				s.handleUpload(rec, r)
+				return rec
+			}
+			sessionID := func(rec *httptest.ResponseRecorder) string {
+				var body struct {
+					SessionID string `json:"sessionId"`
+				}
+				json.Unmarshal(rec.Body.Bytes(), &body)
+				return body.SessionID
+			}
+
+			rec := upload(first)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("first upload status = %d: %s", rec.Code, rec.Body)
+			}
+			firstID := sessionID(rec)
+
+			rec = upload(tt.retry)
+			if rec.Code != tt.status {
+				t.Fatalf("retry status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.wantCode != "" {
+				if code := errorCode(t, rec); code != tt.wantCode {
+					t.Errorf("code = %q, want %q", code, tt.wantCode)
+				}
+				return
+			}
+			if same := sessionID(rec) == firstID; same != tt.sameID {
+				t.Errorf("retry reused the session: %v, want %v", same, tt.sameID)
+			}
+			want := 2
+			if tt.sameID {
+				want = 1
+			}
+			if len(s.sessions) != want {
+				t.Errorf("server has %d sessions, want %d", len(s.sessions), want)
+			}
+		})
+	}
+}
+
+func TestHandleUploadMaxPixels(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxPixels int64
+		status    int
+	}{
+		{"default", 0, http.StatusOK},
+		{"at the limit", 32 * 24, http.StatusOK},
+		{"over the limit", 32*24 - 1, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.maxPixels > 0 {
+				opts = append(opts, WithMaxPixels(tt.maxPixels))
+			}
+			rec := httptest.NewRecorder()
+			New(opts...).handleUpload(rec, uploadRequest(t, "image", bytes.NewReader(testPNG(t, 32, 24))))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				if code := errorCode(t, rec); code != codeTooLarge {
+					t.Errorf("code = %q, want %q", code, codeTooLarge)
+				}
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ function App() {
   const [imageState, setImageState] = useState<ImageState | null>(null);
   const [converted, setConverted] = useState<string | null>(null);
   const [outputSize, setOutputSize] = useState<{ width: number; height: number } | null>(null);
+  const [gridSize, setGridSize] = useState<{ width: number; height: number } | null>(null);
   const [isUploading, setIsUploading] = useState(false);
   const [isConverting, setIsConverting] = useState(false);
   const [error, setError] = useState<string | null>(null);
@@ -61,6 +62,7 @@ function App() {
         });
         setConverted(response.image);
         setOutputSize({ width: response.width, height: response.height });
+        setGridSize({ width: response.gridWidth, height: response.gridHeight });
         setError(null);
       } catch (err) {
         setError(err instanceof Error ? err.message : 'Conversion failed');
@@ -89,6 +91,7 @@ function App() {
     setImageState(null);
     setConverted(null);
     setOutputSize(null);
+    setGridSize(null);
     setError(null);
     setParams({ size: 64, scale: 8, colors: 32 });
   }, []);
@@ -254,6 +257,7 @@ function App() {
                 isConverting={isConverting}
                 onDownload={handleDownload}
                 outputSize={outputSize ?? undefined}
+                gridSize={gridSize ?? undefined}
               />
             </div>
           </div>
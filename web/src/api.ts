@@ -5,12 +5,24 @@ export interface UploadResponse {
   width: number;
   height: number;
   original: string;
+  previewWidth: number;
+  previewHeight: number;
+  dominantColor: string;
+  hash: string;
 }
 
 export interface ConvertResponse {
   image: string;
   width: number;
   height: number;
+  actualColors: number;
+  scale: number;
+  gridWidth: number;
+  gridHeight: number;
+  smallImage?: string;
+  smallWidth?: number;
+  smallHeight?: number;
+  palette?: string[];
 }
 
 export interface ConvertParams {
@@ -18,6 +30,47 @@ export interface ConvertParams {
   size: number;
   scale: number;
   colors: number;
+  mode?: 'pixelart' | 'mosaic';
+  includeSmall?: boolean;
+  lockPalette?: boolean;
+  resetPalette?: boolean;
+}
+
+export interface Capabilities {
+  palettes: string[];
+  modes: string[];
+  ditherModes: string[];
+  sampleModes: string[];
+  formats: string[];
+  uploadTypes: string[];
+}
+
+export interface ApiErrorBody {
+  error: {
+    message: string;
+    code: string;
+  };
+}
+
+export class ApiError extends Error {
+  code: string;
+  status: number;
+
+  constructor(message: string, code: string, status: number) {
+    super(message);
+    this.name = 'ApiError';
+    this.code = code;
+    this.status = status;
+  }
+}
+
+async function toApiError(response: Response, fallback: string): Promise<ApiError> {
+  try {
+    const body: ApiErrorBody = await response.json();
+    return new ApiError(body.error.message, body.error.code, response.status);
+  } catch {
+    return new ApiError(`${fallback}: ${response.statusText}`, 'UNKNOWN', response.status);
+  }
 }
 
 export async function uploadImage(file: File): Promise<UploadResponse> {
@@ -30,7 +83,7 @@ export async function uploadImage(file: File): Promise<UploadResponse> {
   });
 
   if (!response.ok) {
-    throw new Error(`Upload failed: ${response.statusText}`);
+    throw await toApiError(response, 'Upload failed');
   }
 
   return response.json();
@@ -46,7 +99,27 @@ export async function convertImage(params: ConvertParams): Promise<ConvertRespon
   });
 
   if (!response.ok) {
-    throw new Error(`Convert failed: ${response.statusText}`);
+    throw await toApiError(response, 'Convert failed');
+  }
+
+  return response.json();
+}
+
+export interface RecolorParams extends ConvertParams {
+  palette: string[];
+}
+
+export async function recolorImage(params: RecolorParams): Promise<ConvertResponse> {
+  const response = await fetch(`${API_BASE}/recolor`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify(params),
+  });
+
+  if (!response.ok) {
+    throw await toApiError(response, 'Recolor failed');
   }
 
   return response.json();
@@ -62,7 +135,7 @@ export async function downloadImage(params: ConvertParams): Promise<void> {
   });
 
   if (!response.ok) {
-    throw new Error(`Download failed: ${response.statusText}`);
+    throw await toApiError(response, 'Download failed');
   }
 
   const blob = await response.blob();
@@ -75,3 +148,27 @@ export async function downloadImage(params: ConvertParams): Promise<void> {
   document.body.removeChild(a);
   URL.revokeObjectURL(url);
 }
+
+export async function deleteSession(sessionId: string): Promise<void> {
+  const response = await fetch(`${API_BASE}/session/delete`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ sessionId }),
+  });
+
+  if (!response.ok) {
+    throw await toApiError(response, 'Delete failed');
+  }
+}
+
+export async function getCapabilities(): Promise<Capabilities> {
+  const response = await fetch(`${API_BASE}/capabilities`);
+
+  if (!response.ok) {
+    throw await toApiError(response, 'Loading capabilities failed');
+  }
+
+  return response.json();
+}
@@ -6,8 +6,12 @@ interface PreviewPanelProps {
   isConverting: boolean;
   onDownload: () => void;
   outputSize?: { width: number; height: number };
+  gridSize?: { width: number; height: number };
 }
 
+// Tallest the result is displayed at
+const MAX_PREVIEW_HEIGHT = 350;
+
 type ViewMode = 'side-by-side' | 'original' | 'result';
 
 export function PreviewPanel({
@@ -16,9 +20,23 @@ export function PreviewPanel({
   isConverting,
   onDownload,
   outputSize,
+  gridSize,
 }: PreviewPanelProps) {
   const [viewMode, setViewMode] = useState<ViewMode>('side-by-side');
 
+  // Show the result at a whole multiple of its pixel grid so the browser
+  // never scales it by a fractional factor and blurs the edges
+  const pixelMultiple = gridSize
+    ? Math.max(1, Math.floor(MAX_PREVIEW_HEIGHT / gridSize.height))
+    : null;
+  const resultStyle = gridSize && pixelMultiple
+    ? {
+        imageRendering: 'pixelated' as const,
+        width: gridSize.width * pixelMultiple,
+        height: gridSize.height * pixelMultiple,
+      }
+    : { imageRendering: 'pixelated' as const };
+
   if (!original) {
     return (
       <div className="flex items-center justify-center h-96 bg-obsidian rounded-2xl border border-ash">
@@ -163,7 +181,7 @@ export function PreviewPanel({
                       src={converted}
                       alt="Pixel Art"
                       className="max-h-[350px] max-w-full object-contain rounded-lg shadow-2xl"
-                      style={{ imageRendering: 'pixelated' }}
+                      style={resultStyle}
                     />
                     <div className="absolute bottom-2 left-2 bg-void/80 backdrop-blur-sm px-2 py-1 rounded text-xs text-pixel-cyan">
                       Pixel Art